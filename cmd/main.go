@@ -1,15 +1,29 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/brokeraccount"
 	"github.com/wazupwiddat/postrack/server/config"
 	"github.com/wazupwiddat/postrack/server/controllers"
+	"github.com/wazupwiddat/postrack/server/corporateaction"
+	"github.com/wazupwiddat/postrack/server/importbatch"
+	"github.com/wazupwiddat/postrack/server/importjob"
+	"github.com/wazupwiddat/postrack/server/lotselection"
 	"github.com/wazupwiddat/postrack/server/schwab"
+	"github.com/wazupwiddat/postrack/server/schwab/access"
+	"github.com/wazupwiddat/postrack/server/schwab/autosync"
+	"github.com/wazupwiddat/postrack/server/split"
 	"github.com/wazupwiddat/postrack/server/stock"
 	"github.com/wazupwiddat/postrack/server/transaction"
 	"github.com/wazupwiddat/postrack/server/user"
@@ -20,12 +34,21 @@ import (
 var db *gorm.DB
 
 func main() {
+	// amounts are decimals, but clients read them as JSON numbers
+	decimal.MarshalJSONWithoutQuotes = true
+
 	// Config
 	cfg, err := config.NewConfig("./config.yml")
 	if err != nil {
 		log.Fatal(err)
 		return
 	}
+	keyring, err := schwab.NewKeyring(cfg.Schwab.Encryption.CurrentKey, cfg.Schwab.Encryption.Keys)
+	if err != nil {
+		log.Fatal(err)
+	}
+	schwab.SetKeyring(keyring)
+
 	// connect to the database
 	dsn := cfg.MySQLDNS()
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
@@ -33,7 +56,46 @@ func main() {
 		log.Fatal(err)
 	}
 
-	db.AutoMigrate(&user.User{}, &transaction.Transaction{}, &stock.Stock{}, &schwab.SchwabAccess{})
+	if err := transaction.MigrateDates(db); err != nil {
+		log.Fatal(err)
+	}
+	db.AutoMigrate(&user.User{}, &transaction.Transaction{}, &stock.Stock{}, &schwab.SchwabAccess{},
+		&schwab.SchwabSyncState{}, &schwab.OAuthState{}, &brokeraccount.BrokerAccount{}, &importbatch.ImportBatch{}, &accountalias.AccountAlias{},
+		&importjob.ImportJob{}, &importjob.ImportJobFile{}, &importjob.ImportRowError{}, &split.Split{}, &corporateaction.CorporateAction{},
+		&lotselection.LotSelection{})
+
+	if err := transaction.MigrateActionTypes(db); err != nil {
+		log.Fatal(err)
+	}
+	if err := transaction.MigrateOptions(db); err != nil {
+		log.Fatal(err)
+	}
+	if err := split.Seed(db); err != nil {
+		log.Fatal(err)
+	}
+	if err := corporateaction.Seed(db); err != nil {
+		log.Fatal(err)
+	}
+	if err := accountalias.MigrateMasks(db); err != nil {
+		log.Fatal(err)
+	}
+	if err := accountalias.Seed(db); err != nil {
+		log.Fatal(err)
+	}
+
+	migrated, err := schwab.MigrateTokenEncryption(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if migrated > 0 {
+		log.Println("Encrypted Schwab tokens:", migrated)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go access.NewTokenManager(db, cfg).Run(ctx)
+	go autosync.Start(ctx, db, cfg)
 
 	router := mux.NewRouter()
 	controller := controllers.InitController(db, cfg)
@@ -48,11 +110,44 @@ func main() {
 	protected.HandleFunc("/stock/{symbol}", controller.HandleStockRemove).Methods("DELETE")
 	protected.HandleFunc("/summary", controller.HandleSummary).Methods("GET")
 	protected.HandleFunc("/import", controller.HandleImport).Methods("POST")
+	protected.HandleFunc("/import/preview", controller.HandleImportPreview).Methods("POST")
+	protected.HandleFunc("/import/{id:[0-9]+}", controller.HandleImportStatus).Methods("GET")
+	protected.HandleFunc("/import/batches", controller.HandleImportBatches).Methods("GET")
+	protected.HandleFunc("/import/batches/{id}", controller.HandleImportBatchRollback).Methods("DELETE")
+	protected.HandleFunc("/reconcile", controller.HandleReconcile).Methods("GET")
 	protected.HandleFunc("/inspect", controller.HandleInspect).Methods("GET")
 	protected.HandleFunc("/inspect/{symbol}", controller.HandleInspectSymbol).Methods("GET")
+	protected.HandleFunc("/aliases", controller.HandleAccountAliases).Methods("GET")
+	protected.HandleFunc("/aliases", controller.HandleAccountAliasAdd).Methods("POST")
+	protected.HandleFunc("/aliases/{id}", controller.HandleAccountAliasUpdate).Methods("PUT")
+	protected.HandleFunc("/aliases/{id}", controller.HandleAccountAliasRemove).Methods("DELETE")
+	protected.HandleFunc("/splits", controller.HandleSplits).Methods("GET")
+	protected.HandleFunc("/splits", controller.HandleSplitAdd).Methods("POST")
+	protected.HandleFunc("/splits/{id}", controller.HandleSplitUpdate).Methods("PUT")
+	protected.HandleFunc("/splits/{id}", controller.HandleSplitRemove).Methods("DELETE")
+	protected.HandleFunc("/corporateactions", controller.HandleCorporateActions).Methods("GET")
+	protected.HandleFunc("/corporateactions", controller.HandleCorporateActionAdd).Methods("POST")
+	protected.HandleFunc("/corporateactions/{id}", controller.HandleCorporateActionUpdate).Methods("PUT")
+	protected.HandleFunc("/corporateactions/{id}", controller.HandleCorporateActionRemove).Methods("DELETE")
+	protected.HandleFunc("/lots", controller.HandleLots).Methods("GET")
+	protected.HandleFunc("/lots/selections", controller.HandleLotSelections).Methods("GET")
+	protected.HandleFunc("/lots/selections", controller.HandleLotSelectionAdd).Methods("POST")
+	protected.HandleFunc("/lots/selections/{id}", controller.HandleLotSelectionRemove).Methods("DELETE")
+	protected.HandleFunc("/accounts", controller.HandleBrokerAccounts).Methods("GET")
+	protected.HandleFunc("/schwabauthorize", controller.HandleSchwabAuthorize).Methods("GET")
 	protected.HandleFunc("/schwabaccess", controller.HandleSchwabAccess).Methods("POST")
 	protected.HandleFunc("/schwabimporttrans", controller.HandleSchwabImportTrans).Methods("POST")
 	protected.Use(controller.VerifyJWT)
 
-	http.ListenAndServe(fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port), c.Handler(router))
+	srv := &http.Server{
+		Addr:    fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port),
+		Handler: c.Handler(router),
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
 }
@@ -6,6 +6,7 @@ require (
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/gorilla/mux v1.8.0
 	github.com/rs/cors v1.8.3
+	github.com/shopspring/decimal v1.3.1
 	github.com/wazupwiddat/schwab-api v0.1.0
 	golang.org/x/crypto v0.5.0
 	gopkg.in/yaml.v2 v2.4.0
@@ -13,10 +14,7 @@ require (
 	gorm.io/gorm v1.24.3
 )
 
-require (
-	github.com/shopspring/decimal v1.3.1 // indirect
-	github.com/stretchr/testify v1.8.1 // indirect
-)
+require github.com/stretchr/testify v1.8.1 // indirect
 
 require (
 	github.com/go-sql-driver/mysql v1.7.0 // indirect
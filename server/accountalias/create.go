@@ -0,0 +1,11 @@
+package accountalias
+
+import "gorm.io/gorm"
+
+func Create(db *gorm.DB, a *AccountAlias) (uint, error) {
+	err := db.Create(a).Error
+	if err != nil {
+		return 0, err
+	}
+	return a.ID, nil
+}
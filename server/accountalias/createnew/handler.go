@@ -0,0 +1,48 @@
+package createnew
+
+import (
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User        *user.User
+	Mask        string
+	Name        string
+	AccountType string
+	LotMethod   string
+}
+
+type Response struct {
+	Alias *accountalias.AccountAlias
+}
+
+func CreateNewAlias(db *gorm.DB, req *Request) (*Response, error) {
+	if !accountalias.ValidAccountType(req.AccountType) {
+		return nil, &accountalias.InvalidAccountTypeError{AccountType: req.AccountType}
+	}
+	if !accountalias.ValidLotMethod(req.LotMethod) {
+		return nil, &accountalias.InvalidLotMethodError{LotMethod: req.LotMethod}
+	}
+	a := &accountalias.AccountAlias{
+		UserID:      req.User.ID,
+		Mask:        req.Mask,
+		Name:        req.Name,
+		AccountType: req.AccountType,
+		LotMethod:   req.LotMethod,
+	}
+	// the transactions imported before the account had an alias move to it
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := accountalias.Create(tx, a); err != nil {
+			return err
+		}
+		return accountalias.MoveTransactions(tx, req.User.ID, accountalias.StoredNames(a.Mask), a.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Alias: a,
+	}, nil
+}
@@ -0,0 +1,7 @@
+package accountalias
+
+import "gorm.io/gorm"
+
+func Delete(db *gorm.DB, a *AccountAlias) error {
+	return db.Unscoped().Delete(a).Error
+}
@@ -0,0 +1,34 @@
+package accountalias
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type AliasIDDoesNotExistError struct{}
+
+func (*AliasIDDoesNotExistError) Error() string {
+	return "account alias by id does not exist"
+}
+
+func FindByID(db *gorm.DB, userID uint, id uint) (*AccountAlias, error) {
+	var alias AccountAlias
+	res := db.Where(&AccountAlias{ID: id, UserID: userID}).First(&alias)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, &AliasIDDoesNotExistError{}
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return &alias, nil
+}
+
+func FindAllByUser(db *gorm.DB, userID uint) (Aliases, error) {
+	var aliases []AccountAlias
+	res := db.Order("mask").Find(&aliases, &AccountAlias{UserID: userID})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return aliases, nil
+}
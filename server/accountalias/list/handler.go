@@ -0,0 +1,25 @@
+package list
+
+import (
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User *user.User
+}
+
+type Response struct {
+	Aliases []accountalias.AccountAlias
+}
+
+func List(db *gorm.DB, req *Request) (*Response, error) {
+	aliases, err := accountalias.FindAllByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Aliases: aliases,
+	}, nil
+}
@@ -0,0 +1,182 @@
+package accountalias
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"gorm.io/gorm"
+)
+
+const (
+	AccountTypeTaxable = "taxable"
+	AccountTypeIRA     = "ira"
+	AccountTypeRoth    = "roth"
+)
+
+var AccountTypes = []string{AccountTypeTaxable, AccountTypeIRA, AccountTypeRoth}
+
+// AccountAlias maps the masked account number a broker puts in its exports
+// (e.g. XXX953) to the account name transactions are stored under.
+type AccountAlias struct {
+	gorm.Model
+	ID          uint   `gorm:"primary_key"`
+	UserID      uint   `gorm:"uniqueIndex:idx_account_alias_user_mask"`
+	Mask        string `gorm:"size:50;uniqueIndex:idx_account_alias_user_mask"`
+	Name        string `gorm:"size:100"`
+	AccountType string `gorm:"size:20"`
+	// LotMethod is how sales in the account pick the lots they close, FIFO
+	// when empty.
+	LotMethod string `gorm:"size:10"`
+}
+
+type Aliases []AccountAlias
+
+type InvalidAccountTypeError struct {
+	AccountType string
+}
+
+func (e *InvalidAccountTypeError) Error() string {
+	return fmt.Sprintf("account type '%s' must be one of %s", e.AccountType, strings.Join(AccountTypes, ", "))
+}
+
+type InvalidLotMethodError struct {
+	LotMethod string
+}
+
+func (e *InvalidLotMethodError) Error() string {
+	methods := []string{}
+	for _, m := range transaction.LotMethods {
+		methods = append(methods, string(m))
+	}
+	return fmt.Sprintf("lot method '%s' must be one of %s", e.LotMethod, strings.Join(methods, ", "))
+}
+
+// ValidLotMethod reports whether lotMethod is one of the lot methods, or
+// empty for the default.
+func ValidLotMethod(lotMethod string) bool {
+	return lotMethod == "" || transaction.ValidLotMethod(lotMethod)
+}
+
+func ValidAccountType(accountType string) bool {
+	for _, t := range AccountTypes {
+		if t == accountType {
+			return true
+		}
+	}
+	return false
+}
+
+// XXX953, XXXX-1953, ...953
+var accountMaskRegex = regexp.MustCompile(`(?i)(?:X{2,}-?|\.{3}|\*+)(\d{3,})`)
+
+// AccountMask returns the first masked account number found in text, such as
+// the name of an exported file or the account line at the top of it.
+func AccountMask(text string) string {
+	return accountMaskRegex.FindString(text)
+}
+
+// CanonicalMask is the one form every masked account number is stored in
+// when it has no alias: the last three digits after "...", as the Schwab API
+// masks them.  XXX953, XXXX-1953 and ...953 are all ...953, so an account
+// imported from an export and synced from the API is one account.
+func CanonicalMask(mask string) string {
+	m := accountMaskRegex.FindStringSubmatch(mask)
+	if m == nil {
+		return mask
+	}
+	digits := m[1]
+	return "..." + digits[len(digits)-3:]
+}
+
+// Match returns the alias for the first of texts that names one of the
+// aliased accounts, or nil.
+func (a Aliases) Match(texts ...string) *AccountAlias {
+	for _, text := range texts {
+		if text == "" {
+			continue
+		}
+		for idx, alias := range a {
+			if alias.matches(text) {
+				return &a[idx]
+			}
+		}
+	}
+	return nil
+}
+
+// LotMethods returns the lot method of each aliased account that has one,
+// by account name.
+func (a Aliases) LotMethods() map[string]transaction.LotMethod {
+	methods := map[string]transaction.LotMethod{}
+	for _, alias := range a {
+		if alias.LotMethod != "" {
+			methods[alias.Name] = transaction.LotMethod(alias.LotMethod)
+		}
+	}
+	return methods
+}
+
+// Resolve returns the account name for an export: the alias name when one
+// matches, else the masked account number in its canonical form.
+func (a Aliases) Resolve(texts ...string) string {
+	if alias := a.Match(texts...); alias != nil {
+		return alias.Name
+	}
+	for _, text := range texts {
+		if mask := AccountMask(text); mask != "" {
+			return CanonicalMask(mask)
+		}
+	}
+	return ""
+}
+
+// ResolveAccount returns the account name for the account a row of an
+// export names: the alias name when one matches, the masked account number
+// when it has one, else the account as the broker wrote it.
+func (a Aliases) ResolveAccount(account string) string {
+	if name := a.Resolve(account); name != "" {
+		return name
+	}
+	return strings.TrimSpace(account)
+}
+
+// Named reports whether an alias other than the one with the given id has
+// the account name.
+func (a Aliases) Named(name string, id uint) bool {
+	for _, alias := range a {
+		if alias.ID != id && alias.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (a AccountAlias) matches(text string) bool {
+	if a.Mask == "" {
+		return false
+	}
+	if strings.Contains(strings.ToUpper(text), strings.ToUpper(a.Mask)) {
+		return true
+	}
+	// XXX953 should match an export for XXXX-1953 and the other way around
+	aliasDigits := trailingDigits(a.Mask)
+	if len(aliasDigits) < 3 {
+		return false
+	}
+	for _, m := range accountMaskRegex.FindAllStringSubmatch(text, -1) {
+		if strings.HasSuffix(m[1], aliasDigits) || strings.HasSuffix(aliasDigits, m[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func trailingDigits(s string) string {
+	i := len(s)
+	for i > 0 && s[i-1] >= '0' && s[i-1] <= '9' {
+		i--
+	}
+	return s[i:]
+}
@@ -0,0 +1,52 @@
+package accountalias_test
+
+import (
+	"testing"
+
+	"github.com/wazupwiddat/postrack/server/accountalias"
+)
+
+func TestResolve(t *testing.T) {
+	aliases := accountalias.Aliases{
+		{Mask: "XXX953", Name: "Brokerage", AccountType: accountalias.AccountTypeTaxable},
+		{Mask: "XXX286", Name: "IRA", AccountType: accountalias.AccountTypeIRA},
+	}
+	cases := []struct {
+		texts    []string
+		expected string
+	}{
+		{[]string{"XXX953_Transactions_20230204-203011.json"}, "Brokerage"},
+		{[]string{"XXX286_Transactions_20230204-203011.csv"}, "IRA"},
+		{[]string{"export.csv", "Transactions  for account XXXX-1953 as of 02/04/2023 20:30:11 ET"}, "Brokerage"},
+		{[]string{"export.csv", "Transactions  for account Roth ...8286 as of 02/04/2023"}, "IRA"},
+		{[]string{"XXX111_Transactions_20230204-203011.json"}, "...111"},
+		{[]string{"export.csv", "Transactions  for account XXXX-2111 as of 02/04/2023"}, "...111"},
+		{[]string{"export.json"}, ""},
+	}
+	for _, c := range cases {
+		if got := aliases.Resolve(c.texts...); got != c.expected {
+			t.Errorf("Resolve(%v) = %q, expected %q", c.texts, got, c.expected)
+		}
+	}
+}
+
+func TestResolveAccount(t *testing.T) {
+	aliases := accountalias.Aliases{
+		{Mask: "Z12345678", Name: "Roth", AccountType: accountalias.AccountTypeRoth},
+		{Mask: "XXX953", Name: "Brokerage", AccountType: accountalias.AccountTypeTaxable},
+	}
+	cases := []struct {
+		account  string
+		expected string
+	}{
+		{"ROTH IRA Z12345678", "Roth"},
+		{"XXXX-1953", "Brokerage"},
+		{"Individual ...111", "...111"},
+		{" INDIVIDUAL X87654321 ", "INDIVIDUAL X87654321"},
+	}
+	for _, c := range cases {
+		if got := aliases.ResolveAccount(c.account); got != c.expected {
+			t.Errorf("ResolveAccount(%q) = %q, expected %q", c.account, got, c.expected)
+		}
+	}
+}
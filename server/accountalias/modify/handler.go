@@ -0,0 +1,63 @@
+package modify
+
+import (
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User        *user.User
+	ID          uint
+	Mask        string
+	Name        string
+	AccountType string
+	LotMethod   string
+}
+
+type Response struct {
+	Alias *accountalias.AccountAlias
+}
+
+func ModifyAlias(db *gorm.DB, req *Request) (*Response, error) {
+	if !accountalias.ValidAccountType(req.AccountType) {
+		return nil, &accountalias.InvalidAccountTypeError{AccountType: req.AccountType}
+	}
+	if !accountalias.ValidLotMethod(req.LotMethod) {
+		return nil, &accountalias.InvalidLotMethodError{LotMethod: req.LotMethod}
+	}
+	a, err := accountalias.FindByID(db, req.User.ID, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := accountalias.FindAllByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	// the transactions stored under the old name, unless another alias
+	// shares it, and those of an account the new mask now names, move to
+	// the new name
+	from := []string{}
+	if !aliases.Named(a.Name, a.ID) {
+		from = append(from, a.Name)
+	}
+	if req.Mask != a.Mask {
+		from = append(from, accountalias.StoredNames(req.Mask)...)
+	}
+	a.Mask = req.Mask
+	a.Name = req.Name
+	a.AccountType = req.AccountType
+	a.LotMethod = req.LotMethod
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if _, err := accountalias.Update(tx, a); err != nil {
+			return err
+		}
+		return accountalias.MoveTransactions(tx, req.User.ID, from, a.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Alias: a,
+	}, nil
+}
@@ -0,0 +1,20 @@
+package remove
+
+import (
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User *user.User
+	ID   uint
+}
+
+func RemoveAlias(db *gorm.DB, req *Request) error {
+	a, err := accountalias.FindByID(db, req.User.ID, req.ID)
+	if err != nil {
+		return err
+	}
+	return accountalias.Delete(db, a)
+}
@@ -0,0 +1,68 @@
+package accountalias
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// seedAliases are the accounts the Schwab importers used to name with hard
+// coded aliases.  Transactions imported then are stored under these names.
+var seedAliases = []AccountAlias{
+	{Mask: "XXX953", Name: "Brokerage", AccountType: AccountTypeTaxable},
+	{Mask: "XXX286", Name: "IRA", AccountType: AccountTypeIRA},
+}
+
+// Seed adds the old hard coded aliases for the users that have transactions
+// stored under their names, so exports imported again resolve to the same
+// accounts.  Users that already alias the account are left alone.
+func Seed(db *gorm.DB) error {
+	for _, seed := range seedAliases {
+		var userIDs []uint
+		err := db.Table("transactions").Where("account = ? AND deleted_at IS NULL", seed.Name).Distinct().Pluck("user_id", &userIDs).Error
+		if err != nil {
+			return err
+		}
+		for _, userID := range userIDs {
+			aliases, err := FindAllByUser(db, userID)
+			if err != nil {
+				return err
+			}
+			if aliases.Match(seed.Mask) != nil {
+				continue
+			}
+			alias := seed
+			alias.UserID = userID
+			if _, err := Create(db, &alias); err != nil {
+				return err
+			}
+			log.Printf("Added account alias %s for %s for User: %d\n", alias.Mask, alias.Name, userID)
+		}
+	}
+	return nil
+}
+
+// MigrateMasks moves the transactions stored under a masked account number
+// in another form, such as XXX953 from an export, to its canonical form.
+func MigrateMasks(db *gorm.DB) error {
+	var accounts []string
+	err := db.Table("transactions").Distinct().Pluck("account", &accounts).Error
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		if AccountMask(account) != account {
+			continue
+		}
+		canonical := CanonicalMask(account)
+		if canonical == account {
+			continue
+		}
+		err = db.Table("transactions").Where("account = ?", account).Update("account", canonical).Error
+		if err != nil {
+			return err
+		}
+		log.Printf("Moved the transactions of account %s to %s\n", account, canonical)
+	}
+	return nil
+}
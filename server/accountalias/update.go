@@ -0,0 +1,37 @@
+package accountalias
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+func Update(db *gorm.DB, a *AccountAlias) (uint, error) {
+	err := db.Save(a).Error
+	if err != nil {
+		return 0, err
+	}
+	return a.ID, nil
+}
+
+// MoveTransactions stores the user's transactions under any of the from
+// account names under to instead.
+func MoveTransactions(db *gorm.DB, userID uint, from []string, to string) error {
+	names := []string{}
+	for _, name := range from {
+		if name != "" && name != to {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return db.Table("transactions").Where("user_id = ? AND account IN ?", userID, names).Update("account", to).Error
+}
+
+// StoredNames are the account names the transactions of an account with no
+// alias are stored under: the masked number in its canonical form, or the
+// account as the broker wrote it.
+func StoredNames(mask string) []string {
+	return []string{CanonicalMask(mask), strings.TrimSpace(mask)}
+}
@@ -0,0 +1,11 @@
+package brokeraccount
+
+import "gorm.io/gorm"
+
+func Create(db *gorm.DB, a *BrokerAccount) (uint, error) {
+	err := db.Create(a).Error
+	if err != nil {
+		return 0, err
+	}
+	return a.ID, nil
+}
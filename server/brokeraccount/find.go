@@ -0,0 +1,34 @@
+package brokeraccount
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type AccountHashDoesNotExistError struct{}
+
+func (*AccountHashDoesNotExistError) Error() string {
+	return "broker account by hash does not exist"
+}
+
+func FindByHash(db *gorm.DB, userID uint, hash string) (*BrokerAccount, error) {
+	var account BrokerAccount
+	res := db.Where(&BrokerAccount{UserID: userID, AccountHash: hash}).First(&account)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, &AccountHashDoesNotExistError{}
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return &account, nil
+}
+
+func FindAllByUser(db *gorm.DB, userID uint) (BrokerAccounts, error) {
+	var accounts []BrokerAccount
+	res := db.Order("broker, mask").Find(&accounts, &BrokerAccount{UserID: userID})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return accounts, nil
+}
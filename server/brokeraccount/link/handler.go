@@ -0,0 +1,97 @@
+package link
+
+import (
+	"log"
+
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/brokeraccount"
+	"github.com/wazupwiddat/postrack/server/schwab"
+	"github.com/wazupwiddat/postrack/server/user"
+	schwabClient "github.com/wazupwiddat/schwab-api/client"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User        *user.User
+	Client      *schwabClient.SchwabAPIClient
+	AccessToken string
+}
+
+type Response struct {
+	Accounts brokeraccount.BrokerAccounts
+}
+
+// LinkSchwabAccounts stores the accounts the user's Schwab token can see,
+// updating the ones linked before.
+func LinkSchwabAccounts(db *gorm.DB, req *Request) (*Response, error) {
+	accounts, err := FetchSchwabAccounts(req.Client, req.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := accountalias.FindAllByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	accounts.LinkAliases(aliases)
+
+	for idx := range accounts {
+		account := &accounts[idx]
+		account.UserID = req.User.ID
+		existing, err := brokeraccount.FindByHash(db, req.User.ID, account.AccountHash)
+		if err == nil {
+			account.ID = existing.ID
+			account.Model = existing.Model
+			if _, err := brokeraccount.Update(db, account); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if _, ok := err.(*brokeraccount.AccountHashDoesNotExistError); !ok {
+			return nil, err
+		}
+		if _, err := brokeraccount.Create(db, account); err != nil {
+			return nil, err
+		}
+	}
+	return &Response{Accounts: accounts}, nil
+}
+
+// FetchSchwabAccounts combines the account hashes, the account types and the
+// nicknames Schwab has for each account.
+func FetchSchwabAccounts(sClient *schwabClient.SchwabAPIClient, accessToken string) (brokeraccount.BrokerAccounts, error) {
+	numbers, err := schwab.GetAccountNumbers(sClient, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	types := map[string]string{}
+	securities, err := sClient.GetAccounts(accessToken, false)
+	if err != nil {
+		log.Println(err)
+	}
+	for _, s := range securities {
+		types[s.SecuritiesAccount.AccountNumber] = s.SecuritiesAccount.Type
+	}
+
+	nicknames := map[string]string{}
+	pref, err := schwab.GetUserPreference(sClient, accessToken)
+	if err != nil {
+		log.Println(err)
+	} else {
+		for _, a := range pref.Accounts {
+			nicknames[a.AccountNumber] = a.NickName
+		}
+	}
+
+	accounts := brokeraccount.BrokerAccounts{}
+	for _, number := range numbers {
+		accounts = append(accounts, brokeraccount.BrokerAccount{
+			Broker:      brokeraccount.BrokerSchwab,
+			AccountHash: number.HashValue,
+			Mask:        schwab.AccountMask(number.AccountNumber),
+			AccountType: types[number.AccountNumber],
+			Nickname:    nicknames[number.AccountNumber],
+		})
+	}
+	return accounts, nil
+}
@@ -0,0 +1,53 @@
+package link_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/brokeraccount/link"
+	schwabClient "github.com/wazupwiddat/schwab-api/client"
+)
+
+func TestFetchSchwabAccounts(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/accounts/accountNumbers", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"accountNumber": "12345678", "hashValue": "HASH1"}, {"accountNumber": "87654953", "hashValue": "HASH2"}]`))
+	})
+	mux.HandleFunc("/accounts", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"securitiesAccount": {"type": "MARGIN", "accountNumber": "12345678"}},
+			{"securitiesAccount": {"type": "CASH", "accountNumber": "87654953"}}]`))
+	})
+	mux.HandleFunc("/userPreference", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"accounts": [{"accountNumber": "87654953", "type": "BROKERAGE", "nickName": "Roth IRA"}]}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	sClient := schwabClient.NewSchwabClient("client", "secret", "")
+	sClient.TraderBaseURL = server.URL
+
+	accounts, err := link.FetchSchwabAccounts(sClient, "test-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 2 {
+		t.Fatalf("Expected 2 accounts, got %d", len(accounts))
+	}
+	if a := accounts[0]; a.AccountHash != "HASH1" || a.Mask != "...678" || a.AccountType != "MARGIN" || a.Nickname != "" {
+		t.Errorf("Unexpected first account %+v", a)
+	}
+	if a := accounts[1]; a.AccountHash != "HASH2" || a.Mask != "...953" || a.AccountType != "CASH" || a.Nickname != "Roth IRA" {
+		t.Errorf("Unexpected second account %+v", a)
+	}
+
+	aliases := accountalias.Aliases{{ID: 7, Mask: "XXXX-4953", Name: "Roth"}}
+	changed := accounts.LinkAliases(aliases)
+	if len(changed) != 1 || accounts[1].AccountAliasID != 7 || accounts[1].Account != "Roth" {
+		t.Errorf("Expected the second account to link to the Roth alias, got %+v", accounts[1])
+	}
+	if accounts[0].Account != "...678" {
+		t.Errorf("Expected the first account to be named by its mask, got %q", accounts[0].Account)
+	}
+}
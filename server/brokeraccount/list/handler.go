@@ -0,0 +1,34 @@
+package list
+
+import (
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/brokeraccount"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User *user.User
+}
+
+type Response struct {
+	Accounts brokeraccount.BrokerAccounts
+}
+
+// List returns the linked accounts with the names their transactions are
+// stored under.  Aliases added or changed since an account was linked show
+// here, and are stored on the account by the next sync.
+func List(db *gorm.DB, req *Request) (*Response, error) {
+	accounts, err := brokeraccount.FindAllByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := accountalias.FindAllByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	accounts.LinkAliases(aliases)
+	return &Response{
+		Accounts: accounts,
+	}, nil
+}
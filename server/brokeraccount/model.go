@@ -0,0 +1,47 @@
+package brokeraccount
+
+import (
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"gorm.io/gorm"
+)
+
+const BrokerSchwab = "schwab"
+
+// BrokerAccount is a brokerage account the user linked through the broker's
+// API.  The account number itself is not stored, only the broker's hash of
+// it and the masked number its exports show.
+type BrokerAccount struct {
+	gorm.Model
+	ID             uint   `gorm:"primary_key"`
+	UserID         uint   `gorm:"uniqueIndex:idx_broker_account_user_hash"`
+	Broker         string `gorm:"size:20"`
+	AccountHash    string `gorm:"size:100;uniqueIndex:idx_broker_account_user_hash"`
+	Mask           string `gorm:"size:50"`
+	AccountType    string `gorm:"size:30"`
+	Nickname       string `gorm:"size:100"`
+	AccountAliasID uint   `gorm:"index"`
+
+	// Account is the name the account's transactions are stored under.
+	Account string `gorm:"-:all"`
+}
+
+type BrokerAccounts []BrokerAccount
+
+// LinkAliases points each account at the alias for its mask, if any, and
+// fills in the name its transactions are stored under.  It returns the
+// accounts whose linked alias changed.
+func (b BrokerAccounts) LinkAliases(aliases accountalias.Aliases) BrokerAccounts {
+	changed := BrokerAccounts{}
+	for idx := range b {
+		aliasID := uint(0)
+		if alias := aliases.Match(b[idx].Mask); alias != nil {
+			aliasID = alias.ID
+		}
+		b[idx].Account = aliases.Resolve(b[idx].Mask)
+		if b[idx].AccountAliasID != aliasID {
+			b[idx].AccountAliasID = aliasID
+			changed = append(changed, b[idx])
+		}
+	}
+	return changed
+}
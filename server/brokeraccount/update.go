@@ -0,0 +1,29 @@
+package brokeraccount
+
+import (
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"gorm.io/gorm"
+)
+
+func Update(db *gorm.DB, a *BrokerAccount) (uint, error) {
+	err := db.Save(a).Error
+	if err != nil {
+		return 0, err
+	}
+	return a.ID, nil
+}
+
+// Relink points the user's linked accounts at the aliases for their masks,
+// storing the ones whose alias was added or changed since.
+func Relink(db *gorm.DB, userID uint, aliases accountalias.Aliases) error {
+	accounts, err := FindAllByUser(db, userID)
+	if err != nil {
+		return err
+	}
+	for _, changed := range accounts.LinkAliases(aliases) {
+		if _, err := Update(db, &changed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
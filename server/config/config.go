@@ -29,6 +29,14 @@ type Config struct {
 		ClientID     string `yaml:"clientid"`
 		ClientSecret string `yaml:"secret"`
 		AuthRedirect string `yaml:"authredirect"`
+		Encryption   struct {
+			CurrentKey string            `yaml:"currentkey"`
+			Keys       map[string]string `yaml:"keys"` // key id: base64 encoded 32 byte key
+		} `yaml:"encryption"`
+		Sync struct {
+			Interval     string `yaml:"interval"`     // e.g. 24h, empty turns the scheduled sync off
+			LookbackDays int    `yaml:"lookbackdays"` // how far back the first sync of an account goes
+		} `yaml:"sync"`
 	} `yaml:"schwab"`
 }
 
@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/accountalias/createnew"
+	"github.com/wazupwiddat/postrack/server/accountalias/list"
+	"github.com/wazupwiddat/postrack/server/accountalias/modify"
+	"github.com/wazupwiddat/postrack/server/accountalias/remove"
+)
+
+type AccountAliasRequest struct {
+	Mask        string
+	Name        string
+	AccountType string
+	LotMethod   string
+}
+
+func (c Controller) HandleAccountAliases(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := list.List(c.db, &list.Request{User: u})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c Controller) HandleAccountAliasAdd(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	var req AccountAliasRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	// validate the request
+	if req.Mask == "" || req.Name == "" {
+		http.Error(w, "Mask and Name are required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := createnew.CreateNewAlias(c.db, &createnew.Request{
+		User:        u,
+		Mask:        req.Mask,
+		Name:        req.Name,
+		AccountType: req.AccountType,
+		LotMethod:   req.LotMethod,
+	})
+	if err != nil {
+		log.Println(err)
+		switch err.(type) {
+		case *accountalias.InvalidAccountTypeError, *accountalias.InvalidLotMethodError:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c Controller) HandleAccountAliasUpdate(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+	aliasID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Account alias ID must be present to update", http.StatusBadRequest)
+		return
+	}
+
+	var req AccountAliasRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	// validate the request
+	if req.Mask == "" || req.Name == "" {
+		http.Error(w, "Mask and Name are required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := modify.ModifyAlias(c.db, &modify.Request{
+		User:        u,
+		ID:          uint(aliasID),
+		Mask:        req.Mask,
+		Name:        req.Name,
+		AccountType: req.AccountType,
+		LotMethod:   req.LotMethod,
+	})
+	if err != nil {
+		log.Println(err)
+		switch err.(type) {
+		case *accountalias.InvalidAccountTypeError, *accountalias.InvalidLotMethodError:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, ok := err.(*accountalias.AliasIDDoesNotExistError); ok {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c Controller) HandleAccountAliasRemove(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+	aliasID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Account alias ID must be present to remove", http.StatusBadRequest)
+		return
+	}
+
+	err = remove.RemoveAlias(c.db, &remove.Request{User: u, ID: uint(aliasID)})
+	if err != nil {
+		log.Println(err)
+		if _, ok := err.(*accountalias.AliasIDDoesNotExistError); ok {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/wazupwiddat/postrack/server/brokeraccount/list"
+)
+
+func (c Controller) HandleBrokerAccounts(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := list.List(c.db, &list.Request{User: u})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/corporateaction"
+	"github.com/wazupwiddat/postrack/server/corporateaction/createnew"
+	"github.com/wazupwiddat/postrack/server/corporateaction/list"
+	"github.com/wazupwiddat/postrack/server/corporateaction/modify"
+	"github.com/wazupwiddat/postrack/server/corporateaction/remove"
+)
+
+// CorporateActionRequest is a rename, merger or spinoff of Symbol on Date.
+// Denominator defaults to 1.
+type CorporateActionRequest struct {
+	Type         string
+	Symbol       string
+	NewSymbol    string
+	Date         string // 01/02/2006
+	Numerator    int
+	Denominator  int
+	Cash         decimal.Decimal
+	BasisPercent float64
+	Shared       bool
+}
+
+func (c Controller) HandleCorporateActions(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := list.List(c.db, &list.Request{User: u})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c Controller) HandleCorporateActionAdd(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	var req CorporateActionRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	// validate the request
+	date, err := time.Parse("01/02/2006", req.Date)
+	if req.Symbol == "" || err != nil {
+		http.Error(w, "Symbol and Date (01/02/2006) are required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := createnew.CreateNewCorporateAction(c.db, &createnew.Request{
+		User:         u,
+		Type:         req.Type,
+		Symbol:       req.Symbol,
+		NewSymbol:    req.NewSymbol,
+		Date:         date,
+		Numerator:    req.Numerator,
+		Denominator:  denominatorOrOne(req.Denominator),
+		Cash:         req.Cash,
+		BasisPercent: req.BasisPercent,
+		Shared:       req.Shared,
+	})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), corporateActionErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c Controller) HandleCorporateActionUpdate(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+	actionID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Corporate action ID must be present to update", http.StatusBadRequest)
+		return
+	}
+
+	var req CorporateActionRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	// validate the request
+	date, err := time.Parse("01/02/2006", req.Date)
+	if req.Symbol == "" || err != nil {
+		http.Error(w, "Symbol and Date (01/02/2006) are required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := modify.ModifyCorporateAction(c.db, &modify.Request{
+		User:         u,
+		ID:           uint(actionID),
+		Type:         req.Type,
+		Symbol:       req.Symbol,
+		NewSymbol:    req.NewSymbol,
+		Date:         date,
+		Numerator:    req.Numerator,
+		Denominator:  denominatorOrOne(req.Denominator),
+		Cash:         req.Cash,
+		BasisPercent: req.BasisPercent,
+	})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), corporateActionErrorStatus(err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c Controller) HandleCorporateActionRemove(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+	actionID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Corporate action ID must be present to remove", http.StatusBadRequest)
+		return
+	}
+
+	err = remove.RemoveCorporateAction(c.db, &remove.Request{User: u, ID: uint(actionID)})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), corporateActionErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func corporateActionErrorStatus(err error) int {
+	switch err.(type) {
+	case *corporateaction.InvalidCorporateActionError:
+		return http.StatusBadRequest
+	case *corporateaction.SharedCorporateActionError:
+		return http.StatusForbidden
+	case *corporateaction.CorporateActionIDDoesNotExistError:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
-	"os"
+	"strconv"
 
+	"github.com/gorilla/mux"
+	"github.com/wazupwiddat/postrack/server/importjob"
+	"github.com/wazupwiddat/postrack/server/importjob/status"
 	"github.com/wazupwiddat/postrack/server/transaction/importtrans"
 )
 
@@ -32,7 +36,6 @@ func (c Controller) HandleImport(w http.ResponseWriter, r *http.Request) {
 	// Get a reference to the fileHeaders.
 	// They are accessible only after ParseMultipartForm is called
 	files := r.MultipartForm.File["file"]
-	uploadedFiles := []string{}
 	for _, fileHeader := range files {
 		// Restrict the size of each uploaded file to 1MB.
 		// To prevent the aggregate size from exceeding
@@ -42,56 +45,150 @@ func (c Controller) HandleImport(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, fmt.Sprintf("The uploaded file is too big: %s. Please use an file less than 1MB in size", fileHeader.Filename), http.StatusBadRequest)
 			return
 		}
+	}
+
+	job := &importjob.ImportJob{
+		UserID: u.ID,
+		State:  importjob.StateQueued,
+	}
+	if _, err := importjob.Create(c.db, job); err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	uploadedFiles, err := c.saveUploads(job, files)
+	if err != nil {
+		log.Println(err)
+		if e := importtrans.RemoveUploads(c.cfg, job); e != nil {
+			log.Println(e)
+		}
+		job.State = importjob.StateFailed
+		job.Error = err.Error()
+		if _, e := importjob.Update(c.db, job); e != nil {
+			log.Println(e)
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// kick off the import into MySQL
+	go importtrans.ImportUploadedFiles(c.db, c.cfg, u, job)
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":    job.ID,
+		"files": uploadedFiles,
+	})
+}
 
-		// Open the file
+// saveUploads copies the uploaded files into the job's own upload directory.
+func (c Controller) saveUploads(job *importjob.ImportJob, files []*multipart.FileHeader) ([]string, error) {
+	uploadedFiles := []string{}
+	for _, fileHeader := range files {
 		file, err := fileHeader.Open()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
-
 		defer file.Close()
 
-		buff := make([]byte, 512)
-		_, err = file.Read(buff)
+		buff := make([]byte, importtrans.HeaderSize)
+		n, err := file.Read(buff)
+		if err == io.EOF || (err == nil && n == 0) {
+			return nil, &importtrans.EmptyFileError{Name: fileHeader.Filename}
+		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
+		}
+
+		// reject files none of the importers understand up front
+		if importtrans.Detect(buff[:n]) == nil {
+			return nil, &importtrans.UnknownFormatError{Name: fileHeader.Filename}
 		}
 
 		_, err = file.Seek(0, io.SeekStart)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
 
-		err = os.MkdirAll("./uploads", os.ModePerm)
+		name, err := importtrans.SaveUpload(c.cfg, job, fileHeader.Filename, file)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
+		uploadedFiles = append(uploadedFiles, name)
+	}
+	return uploadedFiles, nil
+}
 
-		f, err := os.Create(fmt.Sprintf("./uploads/%s", fileHeader.Filename))
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+func (c Controller) HandleImportStatus(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+	jobID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Import job ID must be present", http.StatusBadRequest)
+		return
+	}
+
+	response, err := status.Status(c.db, &status.Request{User: u, JobID: uint(jobID)})
+	if err != nil {
+		log.Println(err)
+		if _, ok := err.(*importjob.JobIDDoesNotExistError); ok {
+			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-		defer f.Close()
+	json.NewEncoder(w).Encode(response)
+}
 
-		_, err = io.Copy(f, file)
+func (c Controller) HandleImportPreview(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	// 32 MB is the default used by FormFile()
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	uploads := []importtrans.PreviewUpload{}
+	for _, fileHeader := range r.MultipartForm.File["file"] {
+		if fileHeader.Size > MAX_UPLOAD_SIZE {
+			http.Error(w, fmt.Sprintf("The uploaded file is too big: %s. Please use an file less than 1MB in size", fileHeader.Filename), http.StatusBadRequest)
+			return
+		}
+
+		file, err := fileHeader.Open()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		uploadedFiles = append(uploadedFiles, fileHeader.Filename)
+		defer file.Close()
+
+		uploads = append(uploads, importtrans.PreviewUpload{
+			Name:   fileHeader.Filename,
+			Reader: file,
+		})
 	}
 
-	// kick off the import into MySQL
-	go importtrans.ImportUploadedJSONFiles(c.db, c.cfg, u)
+	response, err := importtrans.Preview(c.db, &importtrans.PreviewRequest{User: u, Uploads: uploads})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	// send the JWT token as a response
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"files": uploadedFiles,
-	})
+	json.NewEncoder(w).Encode(response)
 }
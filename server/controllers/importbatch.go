@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/wazupwiddat/postrack/server/importbatch"
+	"github.com/wazupwiddat/postrack/server/importbatch/list"
+	"github.com/wazupwiddat/postrack/server/importbatch/rollback"
+)
+
+func (c Controller) HandleImportBatches(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := list.List(c.db, &list.Request{User: u})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c Controller) HandleImportBatchRollback(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+	batchID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Import batch ID must be present to roll back", http.StatusBadRequest)
+		return
+	}
+
+	response, err := rollback.Rollback(c.db, &rollback.Request{User: u, BatchID: uint(batchID)})
+	if err != nil {
+		log.Println(err)
+		if _, ok := err.(*importbatch.BatchIDDoesNotExistError); ok {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
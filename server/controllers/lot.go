@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/wazupwiddat/postrack/server/lotselection"
+	"github.com/wazupwiddat/postrack/server/lotselection/createnew"
+	"github.com/wazupwiddat/postrack/server/lotselection/list"
+	"github.com/wazupwiddat/postrack/server/lotselection/remove"
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/transaction/lots"
+)
+
+// LotSelectionRequest closes Quantity of the lot opened by
+// OpenTransactionID with the sale CloseTransactionID.
+type LotSelectionRequest struct {
+	CloseTransactionID uint
+	OpenTransactionID  uint
+	Quantity           float64
+}
+
+func (c Controller) HandleLots(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := lots.Lots(c.db, &lots.Request{
+		User:    u,
+		Account: r.URL.Query().Get("account"),
+		Symbol:  r.URL.Query().Get("symbol"),
+	})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c Controller) HandleLotSelections(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := list.List(c.db, &list.Request{User: u})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c Controller) HandleLotSelectionAdd(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	var req LotSelectionRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	// validate the request
+	if req.CloseTransactionID == 0 || req.OpenTransactionID == 0 {
+		http.Error(w, "CloseTransactionID and OpenTransactionID are required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := createnew.CreateNewLotSelection(c.db, &createnew.Request{
+		User:               u,
+		CloseTransactionID: req.CloseTransactionID,
+		OpenTransactionID:  req.OpenTransactionID,
+		Quantity:           req.Quantity,
+	})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), lotSelectionErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c Controller) HandleLotSelectionRemove(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+	selectionID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Lot selection ID must be present to remove", http.StatusBadRequest)
+		return
+	}
+
+	err = remove.RemoveLotSelection(c.db, &remove.Request{User: u, ID: uint(selectionID)})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), lotSelectionErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func lotSelectionErrorStatus(err error) int {
+	switch err.(type) {
+	case *lotselection.InvalidLotSelectionError:
+		return http.StatusBadRequest
+	case *lotselection.LotSelectionIDDoesNotExistError, *transaction.TransactionIDDoesNotExistError:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/wazupwiddat/postrack/server/schwab"
+	"github.com/wazupwiddat/postrack/server/transaction/reconcile"
+)
+
+func (c Controller) HandleReconcile(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := reconcile.Reconcile(c.db, c.cfg, &reconcile.Request{User: u})
+	if err != nil {
+		log.Println(err)
+		if _, ok := err.(*schwab.NoAccessError); ok {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if _, ok := err.(*schwab.ReauthError); ok {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
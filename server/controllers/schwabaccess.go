@@ -9,8 +9,26 @@ import (
 )
 
 type SchwabAccess struct {
-	Code    string
-	Session string
+	Code  string
+	State string
+}
+
+func (c Controller) HandleSchwabAuthorize(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := access.Authorize(c.db, c.cfg, &access.AuthorizeRequest{User: u})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 func (c Controller) HandleSchwabAccess(w http.ResponseWriter, r *http.Request) {
@@ -18,8 +36,8 @@ func (c Controller) HandleSchwabAccess(w http.ResponseWriter, r *http.Request) {
 	json.NewDecoder(r.Body).Decode(&req)
 
 	// validate the request
-	if req.Code == "" || req.Session == "" {
-		http.Error(w, "Authorization Code and Session are required", http.StatusBadRequest)
+	if req.Code == "" || req.State == "" {
+		http.Error(w, "Authorization Code and State are required", http.StatusBadRequest)
 		return
 	}
 
@@ -30,10 +48,25 @@ func (c Controller) HandleSchwabAccess(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	access.NewSchwabAccessToken(c.db, c.cfg, &access.Request{
-		Code: req.Code,
-		User: u,
+	response, err := access.NewSchwabAccessToken(c.db, c.cfg, &access.Request{
+		Code:  req.Code,
+		State: req.State,
+		User:  u,
 	})
+	if err != nil {
+		log.Println(err)
+		if _, ok := err.(*access.InvalidStateError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, ok := err.(*access.CodeExchangeError); ok {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
 }
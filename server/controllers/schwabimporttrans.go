@@ -1,15 +1,80 @@
 package controllers
 
 import (
+	"encoding/json"
+	"log"
 	"net/http"
+	"time"
+
+	"github.com/wazupwiddat/postrack/server/schwab"
+	"github.com/wazupwiddat/postrack/server/transaction/importtrans"
 )
 
+// SchwabImportTrans is the date range to sync, as MM/DD/YYYY.  It defaults
+// to the last 30 days.
+type SchwabImportTrans struct {
+	Start string
+	End   string
+}
+
 func (c Controller) HandleSchwabImportTrans(w http.ResponseWriter, r *http.Request) {
-	// u, err := userFromRequestContext(r, c.db)
-	// if err != nil {
-	// 	log.Println(err)
-	// 	http.Error(w, "Unable to find user", http.StatusUnauthorized)
-	// 	return
-	// }
+	var req SchwabImportTrans
+	json.NewDecoder(r.Body).Decode(&req)
+
+	end := time.Now()
+	if req.End != "" {
+		d, err := time.Parse("01/02/2006", req.End)
+		if err != nil {
+			http.Error(w, "End must be a date like 01/02/2006", http.StatusBadRequest)
+			return
+		}
+		// include the whole end day
+		end = d.Add(24*time.Hour - time.Second)
+	}
+	start := end.AddDate(0, 0, -30)
+	if req.Start != "" {
+		d, err := time.Parse("01/02/2006", req.Start)
+		if err != nil {
+			http.Error(w, "Start must be a date like 01/02/2006", http.StatusBadRequest)
+			return
+		}
+		start = d
+	}
+	if !start.Before(end) {
+		http.Error(w, "Start must be before End", http.StatusBadRequest)
+		return
+	}
+
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := importtrans.SyncSchwabTransactions(c.db, c.cfg, &importtrans.SchwabSyncRequest{
+		User:  u,
+		Start: start,
+		End:   end,
+	})
+	if err != nil {
+		log.Println(err)
+		if _, ok := err.(*schwab.NoAccessError); ok {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if _, ok := err.(*schwab.ReauthError); ok {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if _, ok := err.(*schwab.APIError); ok {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
 }
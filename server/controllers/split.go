@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/wazupwiddat/postrack/server/split"
+	"github.com/wazupwiddat/postrack/server/split/createnew"
+	"github.com/wazupwiddat/postrack/server/split/list"
+	"github.com/wazupwiddat/postrack/server/split/modify"
+	"github.com/wazupwiddat/postrack/server/split/remove"
+)
+
+// SplitRequest is a split of Numerator new shares for every Denominator old
+// ones.  Denominator defaults to 1.
+type SplitRequest struct {
+	Symbol      string
+	Date        string // 01/02/2006
+	Numerator   int
+	Denominator int
+	Status      string
+	Shared      bool
+}
+
+func (c Controller) HandleSplits(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := list.List(c.db, &list.Request{User: u})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c Controller) HandleSplitAdd(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	var req SplitRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	// validate the request
+	date, err := time.Parse("01/02/2006", req.Date)
+	if req.Symbol == "" || err != nil {
+		http.Error(w, "Symbol and Date (01/02/2006) are required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := createnew.CreateNewSplit(c.db, &createnew.Request{
+		User:        u,
+		Symbol:      req.Symbol,
+		Date:        date,
+		Numerator:   req.Numerator,
+		Denominator: denominatorOrOne(req.Denominator),
+		Shared:      req.Shared,
+	})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), splitErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c Controller) HandleSplitUpdate(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+	splitID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Split ID must be present to update", http.StatusBadRequest)
+		return
+	}
+
+	var req SplitRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	// validate the request
+	date, err := time.Parse("01/02/2006", req.Date)
+	if req.Symbol == "" || err != nil {
+		http.Error(w, "Symbol and Date (01/02/2006) are required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := modify.ModifySplit(c.db, &modify.Request{
+		User:        u,
+		ID:          uint(splitID),
+		Symbol:      req.Symbol,
+		Date:        date,
+		Numerator:   req.Numerator,
+		Denominator: denominatorOrOne(req.Denominator),
+		Status:      req.Status,
+	})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), splitErrorStatus(err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c Controller) HandleSplitRemove(w http.ResponseWriter, r *http.Request) {
+	u, err := userFromRequestContext(r, c.db)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Unable to find user", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+	splitID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Split ID must be present to remove", http.StatusBadRequest)
+		return
+	}
+
+	err = remove.RemoveSplit(c.db, &remove.Request{User: u, ID: uint(splitID)})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), splitErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func denominatorOrOne(denominator int) int {
+	if denominator == 0 {
+		return 1
+	}
+	return denominator
+}
+
+func splitErrorStatus(err error) int {
+	switch err.(type) {
+	case *split.InvalidStatusError, *split.InvalidRatioError:
+		return http.StatusBadRequest
+	case *split.SharedSplitError:
+		return http.StatusForbidden
+	case *split.SplitIDDoesNotExistError:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
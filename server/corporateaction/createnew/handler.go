@@ -0,0 +1,59 @@
+package createnew
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/corporateaction"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User         *user.User
+	Type         string
+	Symbol       string
+	NewSymbol    string
+	Date         time.Time
+	Numerator    int
+	Denominator  int
+	Cash         decimal.Decimal
+	BasisPercent float64
+	// Shared actions apply to every user; only admins can add them.
+	Shared bool
+}
+
+type Response struct {
+	CorporateAction *corporateaction.CorporateAction
+}
+
+func CreateNewCorporateAction(db *gorm.DB, req *Request) (*Response, error) {
+	if req.Shared && !req.User.Admin {
+		return nil, &corporateaction.SharedCorporateActionError{}
+	}
+	a := &corporateaction.CorporateAction{
+		UserID:       req.User.ID,
+		Type:         req.Type,
+		Symbol:       strings.ToUpper(req.Symbol),
+		NewSymbol:    strings.ToUpper(req.NewSymbol),
+		Date:         req.Date,
+		Numerator:    req.Numerator,
+		Denominator:  req.Denominator,
+		Cash:         req.Cash,
+		BasisPercent: req.BasisPercent,
+	}
+	if req.Shared {
+		a.UserID = 0
+	}
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	_, err := corporateaction.Create(db, a)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		CorporateAction: a,
+	}, nil
+}
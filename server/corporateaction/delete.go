@@ -0,0 +1,7 @@
+package corporateaction
+
+import "gorm.io/gorm"
+
+func Delete(db *gorm.DB, a *CorporateAction) error {
+	return db.Unscoped().Delete(a).Error
+}
@@ -0,0 +1,47 @@
+package corporateaction
+
+import (
+	"errors"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"gorm.io/gorm"
+)
+
+type CorporateActionIDDoesNotExistError struct{}
+
+func (*CorporateActionIDDoesNotExistError) Error() string {
+	return "corporate action by id does not exist"
+}
+
+// FindByID returns one of the user's corporate actions or a shared one.
+func FindByID(db *gorm.DB, userID uint, id uint) (*CorporateAction, error) {
+	var a CorporateAction
+	res := db.Where("id = ? AND user_id IN ?", id, []uint{0, userID}).First(&a)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, &CorporateActionIDDoesNotExistError{}
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return &a, nil
+}
+
+// FindAllForUser returns the shared corporate actions and the user's own.
+func FindAllForUser(db *gorm.DB, userID uint) (CorporateActions, error) {
+	var actions []CorporateAction
+	res := db.Where("user_id IN ?", []uint{0, userID}).Order("date, symbol").Find(&actions)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return actions, nil
+}
+
+// FindApplied returns the corporate actions to apply to the user's
+// transactions.
+func FindApplied(db *gorm.DB, userID uint) (transaction.CorporateActions, error) {
+	actions, err := FindAllForUser(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	return actions.Applied(), nil
+}
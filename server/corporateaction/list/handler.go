@@ -0,0 +1,25 @@
+package list
+
+import (
+	"github.com/wazupwiddat/postrack/server/corporateaction"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User *user.User
+}
+
+type Response struct {
+	CorporateActions corporateaction.CorporateActions
+}
+
+func List(db *gorm.DB, req *Request) (*Response, error) {
+	actions, err := corporateaction.FindAllForUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		CorporateActions: actions,
+	}, nil
+}
@@ -0,0 +1,127 @@
+package corporateaction
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"gorm.io/gorm"
+)
+
+var Types = []string{transaction.CorporateRename, transaction.CorporateMerger, transaction.CorporateSpinoff}
+
+// CorporateAction is a ticker change, merger or spinoff; see the
+// transaction.Corporate* types for what the fields mean for each.  Actions
+// without a user are shared by everyone and only admins can change them.
+type CorporateAction struct {
+	gorm.Model
+	ID           uint      `gorm:"primary_key"`
+	UserID       uint      `gorm:"index"`
+	Type         string    `gorm:"size:20"`
+	Symbol       string    `gorm:"size:20;index"`
+	NewSymbol    string    `gorm:"size:20"`
+	Date         time.Time `gorm:"type:date"`
+	Numerator    int
+	Denominator  int
+	Cash         decimal.Decimal `gorm:"type:decimal(20,6)"`
+	BasisPercent float64
+}
+
+type CorporateActions []CorporateAction
+
+type InvalidCorporateActionError struct {
+	Reason string
+}
+
+func (e *InvalidCorporateActionError) Error() string {
+	return fmt.Sprintf("Invalid corporate action, %s", e.Reason)
+}
+
+type SharedCorporateActionError struct{}
+
+func (*SharedCorporateActionError) Error() string {
+	return "only admins can change shared corporate actions"
+}
+
+func ValidType(t string) bool {
+	for _, valid := range Types {
+		if valid == t {
+			return true
+		}
+	}
+	return false
+}
+
+// BeforeSave stores the date as a calendar day, see transaction.StorageDay.
+func (a *CorporateAction) BeforeSave(tx *gorm.DB) error {
+	a.Date = transaction.StorageDay(a.Date)
+	return nil
+}
+
+// Validate checks that the action has what its type needs.
+func (a CorporateAction) Validate() error {
+	if !ValidType(a.Type) {
+		return &InvalidCorporateActionError{Reason: fmt.Sprintf("unknown type '%s'", a.Type)}
+	}
+	if a.Symbol == "" {
+		return &InvalidCorporateActionError{Reason: "a symbol is required"}
+	}
+	if a.NewSymbol == a.Symbol {
+		return &InvalidCorporateActionError{Reason: "the new symbol must differ from the symbol"}
+	}
+	if a.Numerator < 0 || a.Denominator < 0 || a.Cash.IsNegative() {
+		return &InvalidCorporateActionError{Reason: "ratio and cash cannot be negative"}
+	}
+	hasRatio := a.Numerator > 0 && a.Denominator > 0
+	switch a.Type {
+	case transaction.CorporateRename:
+		if a.NewSymbol == "" {
+			return &InvalidCorporateActionError{Reason: "a rename needs a new symbol"}
+		}
+	case transaction.CorporateMerger:
+		if a.NewSymbol == "" && a.Cash.IsZero() {
+			return &InvalidCorporateActionError{Reason: "a merger needs a new symbol or cash"}
+		}
+		if a.NewSymbol != "" && !hasRatio {
+			return &InvalidCorporateActionError{Reason: "a stock merger needs a ratio"}
+		}
+	case transaction.CorporateSpinoff:
+		if a.NewSymbol == "" || !hasRatio {
+			return &InvalidCorporateActionError{Reason: "a spinoff needs a new symbol and a ratio"}
+		}
+		if a.BasisPercent < 0 || a.BasisPercent > 100 {
+			return &InvalidCorporateActionError{Reason: "the basis moved must be between 0 and 100 percent"}
+		}
+	}
+	return nil
+}
+
+// Applied returns the actions in the form MergeTransactions takes.  A user's
+// own action replaces a shared one of the same symbol and date.
+func (c CorporateActions) Applied() transaction.CorporateActions {
+	actions := transaction.CorporateActions{}
+	index := map[string]int{}
+	for _, a := range c {
+		ta := transaction.CorporateAction{
+			Type:         a.Type,
+			Symbol:       a.Symbol,
+			NewSymbol:    a.NewSymbol,
+			Date:         transaction.Day(a.Date),
+			Numerator:    a.Numerator,
+			Denominator:  a.Denominator,
+			Cash:         a.Cash,
+			BasisPercent: a.BasisPercent,
+		}
+		key := a.Symbol + a.Date.Format("2006-01-02")
+		if idx, ok := index[key]; ok {
+			if a.UserID != 0 {
+				actions[idx] = ta
+			}
+			continue
+		}
+		index[key] = len(actions)
+		actions = append(actions, ta)
+	}
+	return actions
+}
@@ -0,0 +1,56 @@
+package modify
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/corporateaction"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User         *user.User
+	ID           uint
+	Type         string
+	Symbol       string
+	NewSymbol    string
+	Date         time.Time
+	Numerator    int
+	Denominator  int
+	Cash         decimal.Decimal
+	BasisPercent float64
+}
+
+type Response struct {
+	CorporateAction *corporateaction.CorporateAction
+}
+
+func ModifyCorporateAction(db *gorm.DB, req *Request) (*Response, error) {
+	a, err := corporateaction.FindByID(db, req.User.ID, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if a.UserID == 0 && !req.User.Admin {
+		return nil, &corporateaction.SharedCorporateActionError{}
+	}
+	a.Type = req.Type
+	a.Symbol = strings.ToUpper(req.Symbol)
+	a.NewSymbol = strings.ToUpper(req.NewSymbol)
+	a.Date = req.Date
+	a.Numerator = req.Numerator
+	a.Denominator = req.Denominator
+	a.Cash = req.Cash
+	a.BasisPercent = req.BasisPercent
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	_, err = corporateaction.Update(db, a)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		CorporateAction: a,
+	}, nil
+}
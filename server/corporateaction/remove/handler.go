@@ -0,0 +1,23 @@
+package remove
+
+import (
+	"github.com/wazupwiddat/postrack/server/corporateaction"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User *user.User
+	ID   uint
+}
+
+func RemoveCorporateAction(db *gorm.DB, req *Request) error {
+	a, err := corporateaction.FindByID(db, req.User.ID, req.ID)
+	if err != nil {
+		return err
+	}
+	if a.UserID == 0 && !req.User.Admin {
+		return &corporateaction.SharedCorporateActionError{}
+	}
+	return corporateaction.Delete(db, a)
+}
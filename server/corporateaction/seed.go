@@ -0,0 +1,41 @@
+package corporateaction
+
+import (
+	"time"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"gorm.io/gorm"
+)
+
+// seedActions are shared ticker changes every install starts with.
+var seedActions = []CorporateAction{
+	{Type: transaction.CorporateRename, Symbol: "FB", NewSymbol: "META", Date: time.Date(2022, 6, 9, 0, 0, 0, 0, time.UTC)},
+	{Type: transaction.CorporateRename, Symbol: "SQ", NewSymbol: "XYZ", Date: time.Date(2025, 1, 21, 0, 0, 0, 0, time.UTC)},
+}
+
+// Seed adds the shared corporate actions that are missing.
+func Seed(db *gorm.DB) error {
+	shared, err := FindAllForUser(db, 0)
+	if err != nil {
+		return err
+	}
+	for _, a := range seedActions {
+		if shared.find(a.Symbol, a.Date) != nil {
+			continue
+		}
+		a := a
+		if _, err := Create(db, &a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c CorporateActions) find(symbol string, date time.Time) *CorporateAction {
+	for idx, a := range c {
+		if a.Symbol == symbol && a.Date.Format("2006-01-02") == date.Format("2006-01-02") {
+			return &c[idx]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package corporateaction
+
+import "gorm.io/gorm"
+
+func Update(db *gorm.DB, a *CorporateAction) (uint, error) {
+	err := db.Save(a).Error
+	if err != nil {
+		return 0, err
+	}
+	return a.ID, nil
+}
@@ -0,0 +1,19 @@
+package importbatch
+
+import "gorm.io/gorm"
+
+func Create(db *gorm.DB, b *ImportBatch) (uint, error) {
+	err := db.Create(b).Error
+	if err != nil {
+		return 0, err
+	}
+	return b.ID, nil
+}
+
+func Update(db *gorm.DB, b *ImportBatch) (uint, error) {
+	err := db.Save(b).Error
+	if err != nil {
+		return 0, err
+	}
+	return b.ID, nil
+}
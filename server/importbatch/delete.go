@@ -0,0 +1,7 @@
+package importbatch
+
+import "gorm.io/gorm"
+
+func Delete(db *gorm.DB, b *ImportBatch) error {
+	return db.Unscoped().Delete(b).Error
+}
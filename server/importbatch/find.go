@@ -0,0 +1,34 @@
+package importbatch
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type BatchIDDoesNotExistError struct{}
+
+func (*BatchIDDoesNotExistError) Error() string {
+	return "import batch by id does not exist"
+}
+
+func FindByID(db *gorm.DB, userID uint, id uint) (*ImportBatch, error) {
+	var batch ImportBatch
+	res := db.Where(&ImportBatch{ID: id, UserID: userID}).First(&batch)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, &BatchIDDoesNotExistError{}
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return &batch, nil
+}
+
+func FindAllByUser(db *gorm.DB, userID uint) ([]ImportBatch, error) {
+	var batches []ImportBatch
+	res := db.Order("id desc").Find(&batches, &ImportBatch{UserID: userID})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return batches, nil
+}
@@ -0,0 +1,25 @@
+package list
+
+import (
+	"github.com/wazupwiddat/postrack/server/importbatch"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User *user.User
+}
+
+type Response struct {
+	Batches []importbatch.ImportBatch
+}
+
+func List(db *gorm.DB, req *Request) (*Response, error) {
+	batches, err := importbatch.FindAllByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Batches: batches,
+	}, nil
+}
@@ -0,0 +1,14 @@
+package importbatch
+
+import "gorm.io/gorm"
+
+type ImportBatch struct {
+	gorm.Model
+	ID          uint   `gorm:"primary_key"`
+	UserID      uint   `gorm:"index"`
+	Files       string `gorm:"size:1000"` // source file names, comma separated
+	Format      string `gorm:"size:30"`
+	Inserted    int
+	Skipped     int
+	Conflicting int
+}
@@ -0,0 +1,41 @@
+package rollback
+
+import (
+	"github.com/wazupwiddat/postrack/server/importbatch"
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User    *user.User
+	BatchID uint
+}
+
+type Response struct {
+	Deleted int64
+}
+
+// Rollback deletes exactly the transactions the batch introduced, and then
+// the batch itself.
+func Rollback(db *gorm.DB, req *Request) (*Response, error) {
+	batch, err := importbatch.FindByID(db, req.User.ID, req.BatchID)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Unscoped().Delete(&transaction.Transaction{},
+			"user_id = ? AND import_batch_id = ?", req.User.ID, batch.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		deleted = res.RowsAffected
+		return importbatch.Delete(tx, batch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Deleted: deleted}, nil
+}
@@ -0,0 +1,37 @@
+package importjob
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func Create(db *gorm.DB, j *ImportJob) (uint, error) {
+	err := db.Omit(clause.Associations).Create(j).Error
+	if err != nil {
+		return 0, err
+	}
+	return j.ID, nil
+}
+
+func Update(db *gorm.DB, j *ImportJob) (uint, error) {
+	err := db.Omit(clause.Associations).Save(j).Error
+	if err != nil {
+		return 0, err
+	}
+	return j.ID, nil
+}
+
+func CreateFile(db *gorm.DB, f *ImportJobFile) (uint, error) {
+	err := db.Create(f).Error
+	if err != nil {
+		return 0, err
+	}
+	return f.ID, nil
+}
+
+func CreateRowErrors(db *gorm.DB, errs []ImportRowError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return db.Create(errs).Error
+}
@@ -0,0 +1,28 @@
+package importjob
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type JobIDDoesNotExistError struct{}
+
+func (*JobIDDoesNotExistError) Error() string {
+	return "import job by id does not exist"
+}
+
+func FindByID(db *gorm.DB, userID uint, id uint) (*ImportJob, error) {
+	var job ImportJob
+	res := db.Preload("Files").
+		Preload("Errors", func(db *gorm.DB) *gorm.DB { return db.Order("file, line") }).
+		Where(&ImportJob{ID: id, UserID: userID}).
+		First(&job)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, &JobIDDoesNotExistError{}
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return &job, nil
+}
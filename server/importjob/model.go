@@ -0,0 +1,81 @@
+package importjob
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	StateQueued  = "queued"
+	StateRunning = "running"
+	StateDone    = "done"
+	StateFailed  = "failed"
+)
+
+// messageSize is the size of the error message columns.  Parser messages can
+// quote whole rows, and one that does not fit would fail the insert of every
+// row error of the job.
+const messageSize = 500
+
+type ImportJob struct {
+	gorm.Model
+	ID            uint   `gorm:"primary_key"`
+	UserID        uint   `gorm:"index"`
+	ImportBatchID uint   `gorm:"index"`
+	State         string `gorm:"size:20"`
+	Error         string `gorm:"size:500"`
+	Files         []ImportJobFile
+	Errors        []ImportRowError
+}
+
+// ImportJobFile holds the row counts for one uploaded file of a job.
+type ImportJobFile struct {
+	gorm.Model
+	ID          uint   `gorm:"primary_key"`
+	ImportJobID uint   `gorm:"index"`
+	Name        string `gorm:"size:255"`
+	Format      string `gorm:"size:30"`
+	Rows        int
+	Inserted    int
+	Skipped     int
+	Conflicting int
+	Invalid     int
+	Error       string `gorm:"size:500"`
+}
+
+// ImportRowError is a row that could not be imported.  Line is the line of
+// the uploaded file the row starts on, or 0 when the whole file failed.
+type ImportRowError struct {
+	gorm.Model
+	ID          uint   `gorm:"primary_key"`
+	ImportJobID uint   `gorm:"index"`
+	File        string `gorm:"size:255"`
+	Line        int
+	Message     string `gorm:"size:500"`
+}
+
+func (j *ImportJob) BeforeSave(tx *gorm.DB) error {
+	j.Error = truncate(j.Error, messageSize)
+	return nil
+}
+
+func (f *ImportJobFile) BeforeSave(tx *gorm.DB) error {
+	f.Name = truncate(f.Name, 255)
+	f.Error = truncate(f.Error, messageSize)
+	return nil
+}
+
+func (e *ImportRowError) BeforeSave(tx *gorm.DB) error {
+	e.File = truncate(e.File, 255)
+	e.Message = truncate(e.Message, messageSize)
+	return nil
+}
+
+// truncate cuts s to size bytes without splitting a character.
+func truncate(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	return strings.ToValidUTF8(s[:size], "")
+}
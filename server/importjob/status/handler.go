@@ -0,0 +1,26 @@
+package status
+
+import (
+	"github.com/wazupwiddat/postrack/server/importjob"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User  *user.User
+	JobID uint
+}
+
+type Response struct {
+	Job *importjob.ImportJob
+}
+
+func Status(db *gorm.DB, req *Request) (*Response, error) {
+	job, err := importjob.FindByID(db, req.User.ID, req.JobID)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Job: job,
+	}, nil
+}
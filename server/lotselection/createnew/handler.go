@@ -0,0 +1,46 @@
+package createnew
+
+import (
+	"github.com/wazupwiddat/postrack/server/lotselection"
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User               *user.User
+	CloseTransactionID uint
+	OpenTransactionID  uint
+	Quantity           float64
+}
+
+type Response struct {
+	LotSelection *lotselection.LotSelection
+}
+
+func CreateNewLotSelection(db *gorm.DB, req *Request) (*Response, error) {
+	closing, err := transaction.FindByID(db, req.User.ID, req.CloseTransactionID)
+	if err != nil {
+		return nil, err
+	}
+	opening, err := transaction.FindByID(db, req.User.ID, req.OpenTransactionID)
+	if err != nil {
+		return nil, err
+	}
+	s := &lotselection.LotSelection{
+		UserID:             req.User.ID,
+		CloseTransactionID: req.CloseTransactionID,
+		OpenTransactionID:  req.OpenTransactionID,
+		Quantity:           req.Quantity,
+	}
+	if err := s.Validate(opening, closing); err != nil {
+		return nil, err
+	}
+	_, err = lotselection.Create(db, s)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		LotSelection: s,
+	}, nil
+}
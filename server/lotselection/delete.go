@@ -0,0 +1,7 @@
+package lotselection
+
+import "gorm.io/gorm"
+
+func Delete(db *gorm.DB, s *LotSelection) error {
+	return db.Unscoped().Delete(s).Error
+}
@@ -0,0 +1,34 @@
+package lotselection
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type LotSelectionIDDoesNotExistError struct{}
+
+func (*LotSelectionIDDoesNotExistError) Error() string {
+	return "lot selection by id does not exist"
+}
+
+func FindByID(db *gorm.DB, userID uint, id uint) (*LotSelection, error) {
+	var s LotSelection
+	res := db.Where(&LotSelection{ID: id, UserID: userID}).First(&s)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, &LotSelectionIDDoesNotExistError{}
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return &s, nil
+}
+
+func FindAllByUser(db *gorm.DB, userID uint) (LotSelections, error) {
+	var selections []LotSelection
+	res := db.Order("close_transaction_id, id").Find(&selections, &LotSelection{UserID: userID})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return selections, nil
+}
@@ -0,0 +1,25 @@
+package list
+
+import (
+	"github.com/wazupwiddat/postrack/server/lotselection"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User *user.User
+}
+
+type Response struct {
+	LotSelections []lotselection.LotSelection
+}
+
+func List(db *gorm.DB, req *Request) (*Response, error) {
+	selections, err := lotselection.FindAllByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		LotSelections: selections,
+	}, nil
+}
@@ -0,0 +1,60 @@
+package lotselection
+
+import (
+	"fmt"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"gorm.io/gorm"
+)
+
+// LotSelection names the lot, and how much of it, a sale closes in an
+// account whose lot method is specific identification.  Both are named by
+// the transactions that opened and closed them.
+type LotSelection struct {
+	gorm.Model
+	ID                 uint `gorm:"primary_key"`
+	UserID             uint `gorm:"index"`
+	CloseTransactionID uint `gorm:"index"`
+	OpenTransactionID  uint
+	Quantity           float64
+}
+
+type LotSelections []LotSelection
+
+type InvalidLotSelectionError struct {
+	Reason string
+}
+
+func (e *InvalidLotSelectionError) Error() string {
+	return fmt.Sprintf("Invalid lot selection, %s", e.Reason)
+}
+
+// Validate checks that the sale closing can close the lot opening opened.
+func (s LotSelection) Validate(opening *transaction.Transaction, closing *transaction.Transaction) error {
+	if s.Quantity <= 0 {
+		return &InvalidLotSelectionError{Reason: "the quantity must be positive"}
+	}
+	if opening.Account != closing.Account || opening.Symbol != closing.Symbol {
+		return &InvalidLotSelectionError{Reason: "the lot must be of the same account and symbol as the sale"}
+	}
+	if closing.Date.Before(opening.Date) {
+		return &InvalidLotSelectionError{Reason: "the lot must be opened before the sale"}
+	}
+	if s.Quantity > opening.Quantity {
+		return &InvalidLotSelectionError{Reason: fmt.Sprintf("the lot opened only %v", opening.Quantity)}
+	}
+	return nil
+}
+
+// Selections returns the selections as the lot engine takes them.
+func (s LotSelections) Selections() []transaction.LotSelection {
+	selections := []transaction.LotSelection{}
+	for _, selection := range s {
+		selections = append(selections, transaction.LotSelection{
+			CloseID:  selection.CloseTransactionID,
+			OpenID:   selection.OpenTransactionID,
+			Quantity: selection.Quantity,
+		})
+	}
+	return selections
+}
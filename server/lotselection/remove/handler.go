@@ -0,0 +1,20 @@
+package remove
+
+import (
+	"github.com/wazupwiddat/postrack/server/lotselection"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User *user.User
+	ID   uint
+}
+
+func RemoveLotSelection(db *gorm.DB, req *Request) error {
+	s, err := lotselection.FindByID(db, req.User.ID, req.ID)
+	if err != nil {
+		return err
+	}
+	return lotselection.Delete(db, s)
+}
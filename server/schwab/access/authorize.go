@@ -0,0 +1,42 @@
+package access
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/wazupwiddat/postrack/server/config"
+	"github.com/wazupwiddat/postrack/server/user"
+	schwabClient "github.com/wazupwiddat/schwab-api/client"
+	"gorm.io/gorm"
+)
+
+type AuthorizeRequest struct {
+	User *user.User
+}
+
+type AuthorizeResponse struct {
+	URL   string
+	State string
+}
+
+// Authorize starts linking: it returns the Schwab page the user grants
+// access on, with a state that is checked when Schwab redirects back.
+func Authorize(db *gorm.DB, cfg *config.Config, req *AuthorizeRequest) (*AuthorizeResponse, error) {
+	state, err := IssueState(db, cfg.JWT.Secret, req.User.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	sClient := schwabClient.NewSchwabClient(cfg.Schwab.ClientID, cfg.Schwab.ClientSecret, cfg.Schwab.AuthRedirect)
+
+	query := url.Values{}
+	query.Set("client_id", sClient.ClientID)
+	query.Set("redirect_uri", sClient.AuthRedirect)
+	query.Set("response_type", "code")
+	query.Set("state", state)
+	return &AuthorizeResponse{
+		URL:   fmt.Sprintf("%s/oauth/authorize?%s", strings.TrimSuffix(sClient.OAuthBaseURL, "/"), query.Encode()),
+		State: state,
+	}, nil
+}
@@ -1,9 +1,11 @@
 package access
 
 import (
-	"encoding/json"
+	"fmt"
 	"log"
+	"time"
 
+	"github.com/wazupwiddat/postrack/server/brokeraccount/link"
 	"github.com/wazupwiddat/postrack/server/config"
 	"github.com/wazupwiddat/postrack/server/schwab"
 	"github.com/wazupwiddat/postrack/server/user"
@@ -12,51 +14,76 @@ import (
 )
 
 type Request struct {
-	Code string
-	User *user.User
+	Code  string
+	State string
+	User  *user.User
 }
 
 type Response struct {
 	Id uint
 }
 
+type CodeExchangeError struct {
+	Err error
+}
+
+func (e *CodeExchangeError) Error() string {
+	if e.Err == nil {
+		return "Schwab did not return an access token for the authorization code"
+	}
+	return fmt.Sprintf("Schwab authorization code exchange failed: %s", e.Err)
+}
+
 func NewSchwabAccessToken(db *gorm.DB, cfg *config.Config, req *Request) (*Response, error) {
-	// Delete all access tokens for user
-	db.Unscoped().Delete(&schwab.SchwabAccess{}, "user_id = ?", req.User.ID)
+	if err := ConsumeState(db, cfg.JWT.Secret, req.State, req.User.ID, time.Now()); err != nil {
+		return nil, err
+	}
 
 	// HTTP POST Schwab Client
 	sClient := schwabClient.NewSchwabClient(cfg.Schwab.ClientID, cfg.Schwab.ClientSecret, cfg.Schwab.AuthRedirect)
 	accessToken, err := sClient.CreateAccessToken(req.Code)
 	if err != nil {
-		log.Println(err)
-		return nil, err
+		return nil, &CodeExchangeError{Err: err}
+	}
+	// the client answers a rejected code with no token and no error
+	if accessToken == nil || accessToken.AccessToken == "" || accessToken.RefreshToken == "" {
+		return nil, &CodeExchangeError{}
 	}
+
 	schwabAccess := &schwab.SchwabAccess{}
 	schwabAccess.UserID = req.User.ID
 	schwabAccess.AccessToken = accessToken.AccessToken
 	schwabAccess.RefreshToken = accessToken.RefreshToken
 	schwabAccess.ExpiresIn = accessToken.ExpiresIn
+	schwabAccess.AccessExpiresAt = time.Now().Add(time.Duration(accessToken.ExpiresIn) * time.Second)
 	schwabAccess.Scope = accessToken.Scope
 	schwabAccess.TokenType = accessToken.TokenType
 	schwabAccess.IDToken = accessToken.IDToken
 
-	// Store access details
-	id, err := schwab.Create(db, schwabAccess)
+	// Replace the user's access tokens, keeping the old ones if storing fails
+	var id uint
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&schwab.SchwabAccess{}, "user_id = ?", req.User.ID).Error; err != nil {
+			return err
+		}
+		id, err = schwab.Create(tx, schwabAccess)
+		return err
+	})
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
 
-	// show accounts and positions
-	acct, err := sClient.GetAccounts(schwabAccess.AccessToken, true)
+	// remember which accounts were linked
+	linked, err := link.LinkSchwabAccounts(db, &link.Request{
+		User:        req.User,
+		Client:      sClient,
+		AccessToken: schwabAccess.AccessToken,
+	})
 	if err != nil {
 		log.Println(err)
+	} else {
+		log.Printf("Linked %d Schwab accounts for User: %d\n", len(linked.Accounts), req.User.ID)
 	}
-
-	b, _ := json.MarshalIndent(acct, "", "  ")
-	log.Println(string(b))
-
-	// Start Refresh Token process
-	go RefreshAccessToken(db, cfg, req.User.ID)
-	return &Response{Id: id}, err
+	return &Response{Id: id}, nil
 }
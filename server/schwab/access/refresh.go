@@ -4,9 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"github.com/wazupwiddat/postrack/server/config"
@@ -16,82 +13,155 @@ import (
 	"gorm.io/gorm"
 )
 
-func RefreshAccessToken(db *gorm.DB, cfg *config.Config, userID uint) {
-	exitCh := make(chan os.Signal, 1)
-	signal.Notify(exitCh, os.Interrupt, syscall.SIGINT)
+const (
+	// checkInterval is how often the manager looks for tokens to refresh.
+	checkInterval = 60 * time.Second
+	// refreshMargin is how long before an access token expires it is refreshed.
+	refreshMargin = 90 * time.Second
+	// backoffBase and backoffMax bound the wait after failed refreshes.
+	backoffBase = 30 * time.Second
+	backoffMax  = 30 * time.Minute
+)
+
+// TokenManager keeps every user's Schwab access token fresh.  There is one
+// per server, started from main.
+type TokenManager struct {
+	db  *gorm.DB
+	cfg *config.Config
 
-	ctx, cancel := context.WithCancel(context.Background())
-	go func() {
-		<-exitCh
-		log.Println("Cancel context: Cancel")
-		cancel()
-	}()
+	// failures and retryAt track the users whose last refresh failed
+	failures map[uint]int
+	retryAt  map[uint]time.Time
+}
+
+func NewTokenManager(db *gorm.DB, cfg *config.Config) *TokenManager {
+	return &TokenManager{
+		db:       db,
+		cfg:      cfg,
+		failures: map[uint]int{},
+		retryAt:  map[uint]time.Time{},
+	}
+}
 
-	ticker := time.NewTicker(60 * time.Second)
+// Run refreshes tokens until ctx is done.
+func (m *TokenManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
 	defer ticker.Stop()
 	for {
-		if err := updateAccessTokenIfNeeded(db, cfg, userID); err != nil {
-			fmt.Printf("failed refresh access token: %s", err)
-		}
+		m.RefreshDue(ctx, time.Now())
 		select {
 		case <-ctx.Done():
-			log.Println("Cancel context: Done")
-			os.Exit(0)
+			log.Println("Schwab token manager stopped")
 			return
 		case <-ticker.C:
-			continue
 		}
 	}
 }
 
-func updateAccessTokenIfNeeded(db *gorm.DB,
-	cfg *config.Config, userID uint) error {
-	tokens, rows, err := schwab.FindAllByUser(db, userID)
+// RefreshDue refreshes the access tokens that are about to expire, and marks
+// the ones whose refresh token has expired as needing the user to grant
+// access again.
+func (m *TokenManager) RefreshDue(ctx context.Context, now time.Time) {
+	tokens, err := schwab.FindAllActive(m.db)
 	if err != nil {
 		log.Println(err)
-		return err
+		return
 	}
+	for _, token := range tokens {
+		if ctx.Err() != nil {
+			return
+		}
+		if token.RefreshExpired(now) {
+			log.Println("Schwab refresh token expired for User:", token.UserID)
+			token.NeedsReauth = true
+			if _, err := schwab.Update(m.db, &token); err != nil {
+				log.Println(err)
+			}
+			m.forget(token.UserID)
+			continue
+		}
+		if !RefreshNeeded(token, now) || now.Before(m.retryAt[token.UserID]) {
+			continue
+		}
+		if err := m.refresh(&token); err != nil {
+			m.failures[token.UserID]++
+			wait := Backoff(m.failures[token.UserID])
+			m.retryAt[token.UserID] = now.Add(wait)
+			log.Printf("Failed to refresh access token for User: %d, retrying in %s: %s\n", token.UserID, wait, err)
+			continue
+		}
+		m.forget(token.UserID)
+	}
+}
 
-	if rows == 0 {
-		log.Println("Something went wrong saving the Access Token;  Refresh Token will exit.")
+func (m *TokenManager) refresh(token *schwab.SchwabAccess) error {
+	log.Println("Attempting to Refresh Access Token for User:", token.UserID)
+
+	sClient := schwabClient.NewSchwabClient(m.cfg.Schwab.ClientID, m.cfg.Schwab.ClientSecret, m.cfg.Schwab.AuthRedirect)
+	if err := RefreshAccessToken(sClient, token); err != nil {
 		return err
 	}
+	_, err := schwab.Update(m.db, token)
+	return err
+}
 
-	refreshToken := tokens[0]
-	if rows > 1 {
-		log.Println("Somehow we have more than 1 Access Token;  Using the latest.")
-		refreshToken = tokens[len(tokens)-1]
-	}
+type RefreshError struct {
+	Err error
+}
 
-	// check to see if token is about to expire
-	expires := refreshToken.UpdatedAt.Add(time.Duration(refreshToken.ExpiresIn-90) * time.Second)
-	if time.Now().After(expires) {
-		// Refresh needed
-		log.Println("Attempting to Refresh Access Token for User:", refreshToken.UserID)
-
-		requestToken := models.SchwabAccess{}
-		requestToken.AccessToken = refreshToken.AccessToken
-		requestToken.ExpiresIn = refreshToken.ExpiresIn
-		requestToken.RefreshToken = refreshToken.RefreshToken
-		requestToken.IDToken = refreshToken.IDToken
-
-		sClient := schwabClient.NewSchwabClient(cfg.Schwab.ClientID, cfg.Schwab.ClientSecret, cfg.Schwab.AuthRedirect)
-		err := sClient.RefreshAccessToken(&requestToken)
-		if err != nil {
-			log.Println(err)
-			return err
-		}
+func (e *RefreshError) Error() string {
+	if e.Err == nil {
+		return "Schwab did not return an access token for the refresh token"
+	}
+	return fmt.Sprintf("Schwab access token refresh failed: %s", e.Err)
+}
 
-		refreshToken.AccessToken = requestToken.AccessToken
-		refreshToken.ExpiresIn = requestToken.ExpiresIn
-		refreshToken.RefreshToken = requestToken.RefreshToken
-		refreshToken.IDToken = requestToken.IDToken
+// RefreshAccessToken trades the refresh token of token for new tokens.  The
+// token is only changed when Schwab answers with both of them.
+func RefreshAccessToken(sClient *schwabClient.SchwabAPIClient, token *schwab.SchwabAccess) error {
+	requestToken := models.SchwabAccess{}
+	requestToken.AccessToken = token.AccessToken
+	requestToken.ExpiresIn = token.ExpiresIn
+	requestToken.RefreshToken = token.RefreshToken
+	requestToken.IDToken = token.IDToken
 
-		_, err = schwab.Update(db, &refreshToken)
-		if err != nil {
-			log.Println(err)
-			return err
-		}
+	if err := sClient.RefreshAccessToken(&requestToken); err != nil {
+		return &RefreshError{Err: err}
+	}
+	// the client answers a rejected refresh with no token and no error
+	if requestToken.AccessToken == "" || requestToken.RefreshToken == "" {
+		return &RefreshError{}
 	}
+
+	token.AccessToken = requestToken.AccessToken
+	token.ExpiresIn = requestToken.ExpiresIn
+	token.AccessExpiresAt = time.Now().Add(time.Duration(requestToken.ExpiresIn) * time.Second)
+	token.RefreshToken = requestToken.RefreshToken
+	token.IDToken = requestToken.IDToken
 	return nil
 }
+
+func (m *TokenManager) forget(userID uint) {
+	delete(m.failures, userID)
+	delete(m.retryAt, userID)
+}
+
+// RefreshNeeded reports whether the access token expires within the refresh
+// margin.  Tokens stored before their expiry was recorded are refreshed
+// right away.
+func RefreshNeeded(token schwab.SchwabAccess, now time.Time) bool {
+	return !now.Before(token.AccessExpiresAt.Add(-refreshMargin))
+}
+
+// Backoff is how long to wait before retrying after the given number of
+// failed refreshes in a row.
+func Backoff(failures int) time.Duration {
+	wait := backoffBase
+	for i := 1; i < failures && wait < backoffMax; i++ {
+		wait *= 2
+	}
+	if wait > backoffMax {
+		wait = backoffMax
+	}
+	return wait
+}
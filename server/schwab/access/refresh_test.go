@@ -0,0 +1,94 @@
+package access_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wazupwiddat/postrack/server/schwab"
+	"github.com/wazupwiddat/postrack/server/schwab/access"
+	schwabClient "github.com/wazupwiddat/schwab-api/client"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 30 * time.Minute},
+		{50, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := access.Backoff(tt.failures); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestRefreshNeeded(t *testing.T) {
+	issued := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	token := schwab.SchwabAccess{ExpiresIn: 1800, AccessExpiresAt: issued.Add(30 * time.Minute)}
+	token.CreatedAt = issued
+	// saving the row again, e.g. to re-encrypt it, does not make it fresh
+	token.UpdatedAt = issued.Add(25 * time.Minute)
+
+	if access.RefreshNeeded(token, issued.Add(20*time.Minute)) {
+		t.Errorf("Expected a 20 minute old token to still be good")
+	}
+	if !access.RefreshNeeded(token, issued.Add(29*time.Minute)) {
+		t.Errorf("Expected a token about to expire to need a refresh")
+	}
+	if !access.RefreshNeeded(schwab.SchwabAccess{ExpiresIn: 1800}, issued) {
+		t.Errorf("Expected a token without a recorded expiry to need a refresh")
+	}
+	if token.RefreshExpired(issued.Add(6 * 24 * time.Hour)) {
+		t.Errorf("Expected the refresh token to be good for 7 days")
+	}
+	if !token.RefreshExpired(issued.Add(7*24*time.Hour + time.Minute)) {
+		t.Errorf("Expected the refresh token to expire after 7 days")
+	}
+}
+
+func TestRefreshAccessToken(t *testing.T) {
+	status := http.StatusOK
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"access_token": "new-access", "refresh_token": "new-refresh", "expires_in": 1800}`))
+			return
+		}
+		w.Write([]byte(`{"error": "invalid_client"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	sClient := schwabClient.NewSchwabClient("client", "secret", "")
+	sClient.OAuthBaseURL = server.URL
+
+	for _, status = range []int{http.StatusUnauthorized, http.StatusBadRequest} {
+		token := schwab.SchwabAccess{AccessToken: "old-access", RefreshToken: "old-refresh", ExpiresIn: 1800}
+		if err := access.RefreshAccessToken(sClient, &token); err == nil {
+			t.Errorf("%d: Expected the refresh to fail", status)
+		}
+		if token.AccessToken != "old-access" || token.RefreshToken != "old-refresh" {
+			t.Errorf("%d: Expected the stored tokens to be kept, got %+v", status, token)
+		}
+	}
+
+	status = http.StatusOK
+	token := schwab.SchwabAccess{AccessToken: "old-access", RefreshToken: "old-refresh"}
+	if err := access.RefreshAccessToken(sClient, &token); err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "new-access" || token.RefreshToken != "new-refresh" || token.ExpiresIn != 1800 {
+		t.Errorf("Expected the new tokens, got %+v", token)
+	}
+	if access.RefreshNeeded(token, time.Now()) {
+		t.Errorf("Expected the new access token to be good, expires at %s", token.AccessExpiresAt)
+	}
+}
@@ -0,0 +1,126 @@
+package access
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/wazupwiddat/postrack/server/schwab"
+	"gorm.io/gorm"
+)
+
+// StateTTL is how long the user has to grant access at Schwab.
+const StateTTL = 10 * time.Minute
+
+const statePurpose = "schwab-oauth-state"
+
+type InvalidStateError struct {
+	Reason string
+}
+
+func (e *InvalidStateError) Error() string {
+	return fmt.Sprintf("invalid OAuth state: %s", e.Reason)
+}
+
+// NewState issues the state sent to Schwab's authorize page and returns it
+// with its nonce.  It is a JWT naming the user, signed with a key derived
+// from the JWT secret so it can not be used to log in.
+func NewState(secret string, userID uint, now time.Time) (string, string, error) {
+	raw := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return "", "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(raw)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":      userID,
+		"purpose": statePurpose,
+		"nonce":   nonce,
+		"iat":     now.Unix(),
+		"exp":     now.Add(StateTTL).Unix(),
+	})
+	state, err := token.SignedString(stateKey(secret))
+	if err != nil {
+		return "", "", err
+	}
+	return state, nonce, nil
+}
+
+// ValidateState checks that the state Schwab handed back was issued to the
+// user by NewState and has not expired, and returns its nonce.  Whether the
+// nonce was already used is up to the caller, see ConsumeState.
+func ValidateState(secret string, state string, userID uint, now time.Time) (string, error) {
+	if state == "" {
+		return "", &InvalidStateError{Reason: "missing"}
+	}
+	claims := jwt.MapClaims{}
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(state, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return stateKey(secret), nil
+	})
+	if err != nil {
+		return "", &InvalidStateError{Reason: "bad signature"}
+	}
+	if claims["purpose"] != statePurpose {
+		return "", &InvalidStateError{Reason: "not an OAuth state"}
+	}
+	if !claims.VerifyExpiresAt(now.Unix(), true) {
+		return "", &InvalidStateError{Reason: "expired"}
+	}
+	if id, ok := claims["id"].(float64); !ok || uint(id) != userID {
+		return "", &InvalidStateError{Reason: "issued to another user"}
+	}
+	nonce, ok := claims["nonce"].(string)
+	if !ok || nonce == "" {
+		return "", &InvalidStateError{Reason: "no nonce"}
+	}
+	return nonce, nil
+}
+
+// IssueState issues a state for the user and records its nonce, so the
+// state is only accepted once.
+func IssueState(db *gorm.DB, secret string, userID uint, now time.Time) (string, error) {
+	state, nonce, err := NewState(secret, userID, now)
+	if err != nil {
+		return "", err
+	}
+	_, err = schwab.CreateOAuthState(db, &schwab.OAuthState{
+		UserID:    userID,
+		Nonce:     nonce,
+		ExpiresAt: now.Add(StateTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return state, nil
+}
+
+// ConsumeState validates the state and uses up its nonce, rejecting states
+// that were never recorded or were already handed back.
+func ConsumeState(db *gorm.DB, secret string, state string, userID uint, now time.Time) error {
+	nonce, err := ValidateState(secret, state, userID, now)
+	if err != nil {
+		return err
+	}
+	ok, err := schwab.ConsumeOAuthState(db, userID, nonce, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &InvalidStateError{Reason: "unknown or already used"}
+	}
+	return nil
+}
+
+func stateKey(secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(statePurpose))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,65 @@
+package access_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/wazupwiddat/postrack/server/schwab/access"
+)
+
+func TestValidateState(t *testing.T) {
+	now := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	state, nonce, err := access.NewState("secret", 42, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := access.ValidateState("secret", state, 42, now.Add(time.Minute))
+	if err != nil {
+		t.Errorf("Expected the state to be valid, got %s", err)
+	}
+	if got != nonce {
+		t.Errorf("Expected the nonce %q, got %q", nonce, got)
+	}
+
+	again, againNonce, _ := access.NewState("secret", 42, now)
+	if again == state || againNonce == nonce {
+		t.Errorf("Expected every state to be different")
+	}
+
+	// a login token signed with the JWT secret itself is not a state
+	login, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":  42,
+		"exp": now.Add(time.Hour).Unix(),
+	}).SignedString([]byte("secret"))
+
+	// flip the first character of the signature
+	dot := strings.LastIndex(state, ".")
+	flipped := "A"
+	if state[dot+1] == 'A' {
+		flipped = "B"
+	}
+	tampered := state[:dot+1] + flipped + state[dot+2:]
+
+	tests := []struct {
+		name   string
+		secret string
+		state  string
+		userID uint
+		now    time.Time
+	}{
+		{"missing", "secret", "", 42, now},
+		{"another user", "secret", state, 7, now},
+		{"expired", "secret", state, 42, now.Add(access.StateTTL + time.Second)},
+		{"another secret", "other", state, 42, now},
+		{"tampered", "secret", tampered, 42, now},
+		{"login token", "secret", login, 42, now},
+	}
+	for _, tt := range tests {
+		_, err := access.ValidateState(tt.secret, tt.state, tt.userID, tt.now)
+		if _, ok := err.(*access.InvalidStateError); !ok {
+			t.Errorf("%s: expected an InvalidStateError, got %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package schwab
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	schwabClient "github.com/wazupwiddat/schwab-api/client"
+)
+
+type APIError struct {
+	Status int
+	Body   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Schwab API responded %d: %s", e.Status, e.Body)
+}
+
+// AccountNumber is an entry of accounts/accountNumbers.  The Trader API
+// names the hash "hashValue", which the client's AccountNumbers does not read.
+type AccountNumber struct {
+	AccountNumber string `json:"accountNumber"`
+	HashValue     string `json:"hashValue"`
+}
+
+// UserPreference holds the part of userPreference with the account
+// nicknames the user set up at Schwab.
+type UserPreference struct {
+	Accounts []struct {
+		AccountNumber  string `json:"accountNumber"`
+		PrimaryAccount bool   `json:"primaryAccount"`
+		Type           string `json:"type"`
+		NickName       string `json:"nickName"`
+	} `json:"accounts"`
+}
+
+// accounts/accountNumbers
+func GetAccountNumbers(sClient *schwabClient.SchwabAPIClient, accessToken string) ([]AccountNumber, error) {
+	var numbers []AccountNumber
+	err := GetJSON(sClient, accessToken, fmt.Sprintf("%s/accounts/accountNumbers", sClient.TraderBaseURL), &numbers)
+	return numbers, err
+}
+
+// userPreference
+func GetUserPreference(sClient *schwabClient.SchwabAPIClient, accessToken string) (*UserPreference, error) {
+	var pref UserPreference
+	err := GetJSON(sClient, accessToken, fmt.Sprintf("%s/userPreference", sClient.TraderBaseURL), &pref)
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// GetJSON calls a Trader API endpoint the client has no method for and
+// decodes the response into v.
+func GetJSON(sClient *schwabClient.SchwabAPIClient, accessToken string, endpoint string, v interface{}) error {
+	request, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	request.Header.Set("Accept", "application/json")
+
+	resp, err := sClient.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &APIError{Status: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// AccountMask masks an account number the way Schwab's exports do, so
+// aliases set up for the CSV files also match the linked accounts.
+func AccountMask(accountNumber string) string {
+	if len(accountNumber) <= 3 {
+		return accountNumber
+	}
+	return "..." + accountNumber[len(accountNumber)-3:]
+}
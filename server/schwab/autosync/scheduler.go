@@ -0,0 +1,94 @@
+package autosync
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/wazupwiddat/postrack/server/config"
+	"github.com/wazupwiddat/postrack/server/schwab"
+	"github.com/wazupwiddat/postrack/server/transaction/importtrans"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+// DefaultLookbackDays is how far back the first sync of an account goes when
+// the config does not say; the Trader API serves at most a year per request.
+const DefaultLookbackDays = 365
+
+// Start syncs the Schwab transactions of every linked user once per the
+// configured interval until ctx is done.  It does nothing when no interval
+// is configured.
+func Start(ctx context.Context, db *gorm.DB, cfg *config.Config) {
+	if cfg.Schwab.Sync.Interval == "" {
+		log.Println("Scheduled Schwab sync is off")
+		return
+	}
+	interval, err := time.ParseDuration(cfg.Schwab.Sync.Interval)
+	if err != nil || interval <= 0 {
+		log.Println("Scheduled Schwab sync is off, invalid interval:", cfg.Schwab.Sync.Interval)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			SyncAll(db, cfg, now)
+		}
+	}
+}
+
+// SyncAll fetches what is new for every user whose Schwab access is still
+// good.
+func SyncAll(db *gorm.DB, cfg *config.Config, now time.Time) {
+	userIDs, err := schwab.FindLinkedUserIDs(db)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	lookback := cfg.Schwab.Sync.LookbackDays
+	if lookback <= 0 || lookback > DefaultLookbackDays {
+		lookback = DefaultLookbackDays
+	}
+	for _, userID := range userIDs {
+		if err := syncUser(db, cfg, userID, now.AddDate(0, 0, -lookback), now); err != nil {
+			log.Printf("Scheduled Schwab sync for user %d failed: %s\n", userID, err)
+		}
+	}
+}
+
+func syncUser(db *gorm.DB, cfg *config.Config, userID uint, start time.Time, end time.Time) error {
+	tokens, rows, err := schwab.FindAllByUser(db, userID)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return nil
+	}
+	if token := tokens[len(tokens)-1]; token.NeedsReauth || token.RefreshExpired(end) {
+		log.Printf("Schwab access for user %d has expired, skipping sync\n", userID)
+		return nil
+	}
+
+	u, err := user.FindByID(db, userID)
+	if err != nil {
+		return err
+	}
+	response, err := importtrans.SyncSchwabTransactions(db, cfg, &importtrans.SchwabSyncRequest{
+		User:        u,
+		Start:       start,
+		End:         end,
+		Incremental: true,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Scheduled Schwab sync for user %d: %d accounts, inserted %d, skipped %d, conflicting %d\n",
+		userID, response.Accounts, response.Inserted, response.Skipped, response.Conflicting)
+	return nil
+}
@@ -1,13 +1,29 @@
 package schwab
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 func Create(db *gorm.DB, a *SchwabAccess) (uint, error) {
 	err := db.Create(a).Error
 	if err != nil {
-		return 0, db.Error
+		return 0, err
 	}
 	return a.ID, nil
 }
+
+// CreateOAuthState records a state issued to the user, dropping the ones
+// that expired without being used.
+func CreateOAuthState(db *gorm.DB, s *OAuthState) (uint, error) {
+	err := db.Unscoped().Where("user_id = ? AND expires_at < ?", s.UserID, time.Now()).Delete(&OAuthState{}).Error
+	if err != nil {
+		return 0, err
+	}
+	err = db.Create(s).Error
+	if err != nil {
+		return 0, err
+	}
+	return s.ID, nil
+}
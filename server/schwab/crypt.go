@@ -0,0 +1,222 @@
+package schwab
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Encrypted values look like enc:<key id>:<wrapped data key>:<ciphertext>.
+// Each value has its own data key, sealed with the master key named by the
+// key id, so master keys can be rotated by adding a new one and making it
+// current; MigrateTokenEncryption then re-wraps the stored tokens.
+const encryptedPrefix = "enc:"
+
+type NoKeyringError struct{}
+
+func (*NoKeyringError) Error() string {
+	return "no encryption key is configured for Schwab tokens"
+}
+
+type UnknownKeyError struct {
+	KeyID string
+}
+
+func (e *UnknownKeyError) Error() string {
+	return fmt.Sprintf("unknown encryption key %q", e.KeyID)
+}
+
+type Keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+var keyring *Keyring
+
+// SetKeyring sets the keys the Schwab tokens are encrypted with.
+func SetKeyring(k *Keyring) {
+	keyring = k
+}
+
+// NewKeyring builds a keyring from base64 encoded 32 byte master keys by id.
+// New values are encrypted with the current key.
+func NewKeyring(current string, keys map[string]string) (*Keyring, error) {
+	k := &Keyring{current: current, keys: map[string][]byte{}}
+	for id, encoded := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid encryption key id %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %q must be 32 bytes, base64 encoded", id)
+		}
+		k.keys[id] = key
+	}
+	if _, ok := k.keys[current]; !ok {
+		return nil, &UnknownKeyError{KeyID: current}
+	}
+	return k, nil
+}
+
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.current], dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + k.current + ":" +
+		base64.StdEncoding.EncodeToString(wrapped) + ":" +
+		base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt returns the plaintext and the id of the key it was encrypted with.
+// Values stored before encryption was added are returned as they are, with
+// no key id.
+func (k *Keyring) Decrypt(value string) (string, string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, "", nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", "", fmt.Errorf("malformed encrypted value")
+	}
+	masterKey, ok := k.keys[parts[0]]
+	if !ok {
+		return "", "", &UnknownKeyError{KeyID: parts[0]}
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", "", err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", "", err
+	}
+	dataKey, err := open(masterKey, wrapped)
+	if err != nil {
+		return "", "", err
+	}
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return "", "", err
+	}
+	return string(plaintext), parts[0], nil
+}
+
+func seal(key []byte, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key []byte, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted value is too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (a *SchwabAccess) tokens() []*string {
+	return []*string{&a.AccessToken, &a.RefreshToken, &a.IDToken}
+}
+
+// BeforeSave encrypts the tokens on their way into the database.
+func (a *SchwabAccess) BeforeSave(tx *gorm.DB) error {
+	if keyring == nil {
+		return &NoKeyringError{}
+	}
+	for _, token := range a.tokens() {
+		if *token == "" {
+			continue
+		}
+		encrypted, err := keyring.Encrypt(*token)
+		if err != nil {
+			return err
+		}
+		*token = encrypted
+	}
+	return nil
+}
+
+// AfterSave leaves the caller with the plaintext tokens it saved.
+func (a *SchwabAccess) AfterSave(tx *gorm.DB) error {
+	return a.decrypt()
+}
+
+// AfterFind decrypts the tokens read from the database.
+func (a *SchwabAccess) AfterFind(tx *gorm.DB) error {
+	return a.decrypt()
+}
+
+func (a *SchwabAccess) decrypt() error {
+	if keyring == nil {
+		return &NoKeyringError{}
+	}
+	a.stale = false
+	for _, token := range a.tokens() {
+		if *token == "" {
+			continue
+		}
+		plaintext, keyID, err := keyring.Decrypt(*token)
+		if err != nil {
+			return err
+		}
+		if keyID != keyring.current {
+			a.stale = true
+		}
+		*token = plaintext
+	}
+	return nil
+}
+
+// MigrateTokenEncryption encrypts the tokens stored in plaintext and
+// re-encrypts the ones sealed with a key that is no longer current.  It
+// returns how many rows were rewritten.
+func MigrateTokenEncryption(db *gorm.DB) (int, error) {
+	var tokens []SchwabAccess
+	if err := db.Find(&tokens).Error; err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for idx := range tokens {
+		if !tokens[idx].stale {
+			continue
+		}
+		if _, err := Update(db, &tokens[idx]); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
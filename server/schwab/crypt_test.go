@@ -0,0 +1,89 @@
+package schwab_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/wazupwiddat/postrack/server/schwab"
+)
+
+var (
+	key1 = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	key2 = base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+)
+
+func TestKeyringRoundTrip(t *testing.T) {
+	k, err := schwab.NewKeyring("k1", map[string]string{"k1": key1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := k.Encrypt("access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encrypted, "enc:k1:") || strings.Contains(encrypted, "access-token") {
+		t.Errorf("Expected an encrypted value tagged with k1, got %q", encrypted)
+	}
+	again, _ := k.Encrypt("access-token")
+	if again == encrypted {
+		t.Errorf("Expected each value to get its own data key")
+	}
+
+	plaintext, keyID, err := k.Decrypt(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext != "access-token" || keyID != "k1" {
+		t.Errorf("Expected access-token sealed with k1, got %q with %q", plaintext, keyID)
+	}
+
+	// rows stored before encryption read back as they are
+	plaintext, keyID, err = k.Decrypt("legacy-token")
+	if err != nil || plaintext != "legacy-token" || keyID != "" {
+		t.Errorf("Expected legacy-token to pass through, got %q %q %v", plaintext, keyID, err)
+	}
+
+	tampered := encrypted[:len(encrypted)-4] + "AAA="
+	if _, _, err := k.Decrypt(tampered); err == nil {
+		t.Errorf("Expected a tampered value to fail to decrypt")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	old, err := schwab.NewKeyring("k1", map[string]string{"k1": key1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := old.Encrypt("refresh-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := schwab.NewKeyring("k2", map[string]string{"k1": key1, "k2": key2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, keyID, err := rotated.Decrypt(encrypted)
+	if err != nil || plaintext != "refresh-token" || keyID != "k1" {
+		t.Errorf("Expected the old key to still decrypt, got %q %q %v", plaintext, keyID, err)
+	}
+	reencrypted, _ := rotated.Encrypt(plaintext)
+	if !strings.HasPrefix(reencrypted, "enc:k2:") {
+		t.Errorf("Expected new values to use k2, got %q", reencrypted)
+	}
+
+	retired, _ := schwab.NewKeyring("k2", map[string]string{"k2": key2})
+	if _, _, err := retired.Decrypt(encrypted); err == nil {
+		t.Errorf("Expected a value sealed with a retired key to fail")
+	}
+}
+
+func TestNewKeyringInvalid(t *testing.T) {
+	if _, err := schwab.NewKeyring("k1", nil); err == nil {
+		t.Errorf("Expected a missing current key to fail")
+	}
+	if _, err := schwab.NewKeyring("k1", map[string]string{"k1": "c2hvcnQ="}); err == nil {
+		t.Errorf("Expected a short key to fail")
+	}
+}
@@ -0,0 +1,18 @@
+package schwab
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ConsumeOAuthState deletes the state the user was issued with the nonce.
+// It reports false when there is no such state that is still good, because
+// it was never issued, has expired or was already used.
+func ConsumeOAuthState(db *gorm.DB, userID uint, nonce string, now time.Time) (bool, error) {
+	res := db.Unscoped().Where("user_id = ? AND nonce = ? AND expires_at >= ?", userID, nonce, now).Delete(&OAuthState{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
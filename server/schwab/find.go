@@ -1,9 +1,27 @@
 package schwab
 
 import (
+	"fmt"
+
 	"gorm.io/gorm"
 )
 
+type NoAccessError struct {
+	UserID uint
+}
+
+func (e *NoAccessError) Error() string {
+	return fmt.Sprintf("No Schwab access token for user %d", e.UserID)
+}
+
+type ReauthError struct {
+	UserID uint
+}
+
+func (e *ReauthError) Error() string {
+	return fmt.Sprintf("Schwab access for user %d has expired and must be granted again", e.UserID)
+}
+
 func FindAllByUser(db *gorm.DB, userId uint) ([]SchwabAccess, int64, error) {
 	var tokens []SchwabAccess
 	res := db.Find(&tokens, &SchwabAccess{UserID: userId})
@@ -12,3 +30,50 @@ func FindAllByUser(db *gorm.DB, userId uint) ([]SchwabAccess, int64, error) {
 	}
 	return tokens, res.RowsAffected, nil
 }
+
+// FindActiveByUser returns the token to call the Schwab API with for the
+// user: the latest one, as long as it is still good.
+func FindActiveByUser(db *gorm.DB, userId uint) (*SchwabAccess, error) {
+	tokens, rows, err := FindAllByUser(db, userId)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, &NoAccessError{UserID: userId}
+	}
+	token := tokens[len(tokens)-1]
+	if token.NeedsReauth {
+		return nil, &ReauthError{UserID: userId}
+	}
+	return &token, nil
+}
+
+// FindAllActive returns the tokens of every user that does not need to
+// grant access again.
+func FindAllActive(db *gorm.DB) ([]SchwabAccess, error) {
+	var tokens []SchwabAccess
+	res := db.Where("needs_reauth = ?", false).Find(&tokens)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return tokens, nil
+}
+
+// FindLinkedUserIDs returns every user that has granted Schwab access.
+func FindLinkedUserIDs(db *gorm.DB) ([]uint, error) {
+	var userIDs []uint
+	res := db.Model(&SchwabAccess{}).Distinct().Pluck("user_id", &userIDs)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return userIDs, nil
+}
+
+func FindSyncStatesByUser(db *gorm.DB, userId uint) ([]SchwabSyncState, error) {
+	var states []SchwabSyncState
+	res := db.Find(&states, &SchwabSyncState{UserID: userId})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return states, nil
+}
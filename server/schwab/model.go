@@ -1,6 +1,10 @@
 package schwab
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type SchwabAccess struct {
 	gorm.Model
@@ -9,7 +13,46 @@ type SchwabAccess struct {
 	ExpiresIn    int    `json:"expires_in"`
 	TokenType    string `gorm:"size:10" json:"token_type"`
 	Scope        string `gorm:"size:30" json:"scope"`
-	RefreshToken string `gorm:"size:100" json:"refresh_token"` // valid for 7 days
-	AccessToken  string `gorm:"size:100" json:"access_token"`  // valid for 30 minutes
-	IDToken      string `gorm:"size:400" json:"id_token"`      // JWT
+	RefreshToken string `gorm:"size:400" json:"refresh_token"` // valid for 7 days, encrypted
+	AccessToken  string `gorm:"size:400" json:"access_token"`  // valid for 30 minutes, encrypted
+	IDToken      string `gorm:"size:2000" json:"id_token"`     // JWT, encrypted
+	NeedsReauth  bool   `json:"-"`                             // the refresh token expired
+
+	// AccessExpiresAt is when the access token expires.  It is kept apart
+	// from UpdatedAt, which moves whenever the row is saved.
+	AccessExpiresAt time.Time `json:"-"`
+
+	// stale is set when a token was read in plaintext or sealed with a key
+	// that is no longer current
+	stale bool `gorm:"-"`
+}
+
+// RefreshTokenTTL is how long a refresh token is good for after the user
+// grants access.
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+// RefreshExpired reports whether the user has to grant access again.
+func (a SchwabAccess) RefreshExpired(now time.Time) bool {
+	return now.After(a.CreatedAt.Add(RefreshTokenTTL))
+}
+
+// SchwabSyncState is how far the transactions of a linked account have been
+// synced, so the next sync only fetches what is new.
+type SchwabSyncState struct {
+	gorm.Model
+	ID           uint   `gorm:"primary_key" json:"-"`
+	UserID       uint   `gorm:"uniqueIndex:idx_sync_user_account"`
+	AccountHash  string `gorm:"size:100;uniqueIndex:idx_sync_user_account"`
+	Account      string `gorm:"size:100"`
+	LastSyncedAt time.Time
+}
+
+// OAuthState is a state issued to a user for linking Schwab.  Each one is
+// deleted when Schwab redirects back with it, so it can only be used once.
+type OAuthState struct {
+	gorm.Model
+	ID        uint   `gorm:"primary_key" json:"-"`
+	UserID    uint   `gorm:"index"`
+	Nonce     string `gorm:"size:30;uniqueIndex"`
+	ExpiresAt time.Time
 }
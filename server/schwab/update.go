@@ -5,7 +5,15 @@ import "gorm.io/gorm"
 func Update(db *gorm.DB, a *SchwabAccess) (uint, error) {
 	err := db.Save(a).Error
 	if err != nil {
-		return 0, db.Error
+		return 0, err
 	}
 	return a.ID, nil
 }
+
+func UpdateSyncState(db *gorm.DB, s *SchwabSyncState) (uint, error) {
+	err := db.Save(s).Error
+	if err != nil {
+		return 0, err
+	}
+	return s.ID, nil
+}
@@ -0,0 +1,11 @@
+package split
+
+import "gorm.io/gorm"
+
+func Create(db *gorm.DB, s *Split) (uint, error) {
+	err := db.Create(s).Error
+	if err != nil {
+		return 0, err
+	}
+	return s.ID, nil
+}
@@ -0,0 +1,52 @@
+package createnew
+
+import (
+	"strings"
+	"time"
+
+	"github.com/wazupwiddat/postrack/server/split"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User   *user.User
+	Symbol string
+	Date   time.Time
+	// Numerator new shares for every Denominator old ones.
+	Numerator   int
+	Denominator int
+	// Shared splits apply to every user; only admins can add them.
+	Shared bool
+}
+
+type Response struct {
+	Split *split.Split
+}
+
+func CreateNewSplit(db *gorm.DB, req *Request) (*Response, error) {
+	if req.Shared && !req.User.Admin {
+		return nil, &split.SharedSplitError{}
+	}
+	s := &split.Split{
+		UserID:      req.User.ID,
+		Symbol:      strings.ToUpper(req.Symbol),
+		Date:        req.Date,
+		Numerator:   req.Numerator,
+		Denominator: req.Denominator,
+		Status:      split.StatusConfirmed,
+	}
+	if req.Shared {
+		s.UserID = 0
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	_, err := split.Create(db, s)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Split: s,
+	}, nil
+}
@@ -0,0 +1,7 @@
+package split
+
+import "gorm.io/gorm"
+
+func Delete(db *gorm.DB, s *Split) error {
+	return db.Unscoped().Delete(s).Error
+}
@@ -0,0 +1,129 @@
+package split
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+// Detect proposes a split for every "Stock Split", "Reverse Split" or
+// "Options Frwd Split" row that no known split accounts for.  The ratio is
+// worked out from the shares held before the split and the shares it added,
+// or for a reverse split the shares it left; it is left at 0/0 for the user
+// to fill in when that is not possible.
+func Detect(trans transaction.Transactions, known Splits) Splits {
+	// holdings are counted after the known splits, so a ratio found here is
+	// in the same terms as the shares the split added
+	adjusted := make(transaction.Transactions, len(trans))
+	copy(adjusted, trans)
+	adjusted = *adjusted.ApplySplits(known.Confirmed())
+	sort.Stable(transaction.ByDate(adjusted))
+
+	proposals := Splits{}
+	holdings := map[string]float64{}
+	for _, tran := range adjusted {
+		date := tran.Date
+		symbol := transaction.SymbolFromOptionSymbol(tran.Symbol)
+		key := tran.Account + "|" + symbol
+
+		switch a := tran.ActionType(); {
+		case a == transaction.ActionSplit && transaction.IsOption(tran):
+			if known.Near(symbol, date) != nil || proposals.Near(symbol, date) != nil {
+				continue
+			}
+			proposals = proposals.propose(symbol, date, 0, 0)
+		case a == transaction.ActionSplit:
+			held := holdings[key]
+			holdings[key] += tran.Quantity
+			if known.Near(symbol, date) != nil {
+				continue
+			}
+			if held <= 0 {
+				proposals = proposals.propose(symbol, date, 0, 0)
+				continue
+			}
+			num, den := splitRatio((held + tran.Quantity) / held)
+			proposals = proposals.propose(symbol, date, num, den)
+		case a == transaction.ActionReverseSplit:
+			// the old shares come out in a row of their own, which only the
+			// row of the new shares needs
+			if tran.Quantity <= 0 {
+				continue
+			}
+			held := holdings[key]
+			holdings[key] = tran.Quantity
+			if known.Near(symbol, date) != nil {
+				continue
+			}
+			if held <= 0 {
+				proposals = proposals.propose(symbol, date, 0, 0)
+				continue
+			}
+			num, den := splitRatio(tran.Quantity / held)
+			proposals = proposals.propose(symbol, date, num, den)
+		case transaction.IsOption(tran):
+		case a == transaction.ActionBuy || a == transaction.ActionReinvest:
+			holdings[key] += tran.Quantity
+		case a == transaction.ActionSell:
+			holdings[key] -= tran.Quantity
+		}
+	}
+	return proposals
+}
+
+// propose adds a proposal, or fills in the ratio of the one already near
+// the date.
+func (s Splits) propose(symbol string, date time.Time, num int, den int) Splits {
+	if p := s.Near(symbol, date); p != nil {
+		if p.Numerator == 0 {
+			p.Numerator = num
+			p.Denominator = den
+		}
+		return s
+	}
+	return append(s, Split{Symbol: symbol, Date: date, Numerator: num, Denominator: den, Status: StatusProposed})
+}
+
+// maxDenominator bounds the ratios splitRatio looks for; splits are never
+// odder than 1 for 10 or 10 for 9.
+const maxDenominator = 10
+
+// splitRatio is the simplest num/den, other than 1, within 1% of the factor
+// the split changed holdings by, or 0/0.
+func splitRatio(factor float64) (int, int) {
+	for den := 1; den <= maxDenominator; den++ {
+		num := int(math.Round(factor * float64(den)))
+		if num <= 0 || num == den {
+			continue
+		}
+		if math.Abs(float64(num)/float64(den)-factor) <= 0.01*factor {
+			return num, den
+		}
+	}
+	return 0, 0
+}
+
+// Propose stores the splits Detect finds in the user's transactions as
+// proposals for the user to confirm or reject.
+func Propose(db *gorm.DB, u *user.User) (Splits, error) {
+	known, err := FindAllForUser(db, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	trans, err := transaction.FindAllByUser(db, u)
+	if err != nil {
+		return nil, err
+	}
+	proposals := Detect(trans, known)
+	for idx := range proposals {
+		proposals[idx].UserID = u.ID
+		if _, err := Create(db, &proposals[idx]); err != nil {
+			return nil, err
+		}
+	}
+	return proposals, nil
+}
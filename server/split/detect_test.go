@@ -0,0 +1,107 @@
+package split_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wazupwiddat/postrack/server/split"
+	"github.com/wazupwiddat/postrack/server/transaction"
+)
+
+func date(value string) time.Time {
+	d, _ := time.Parse("01/02/2006", value)
+	return d
+}
+
+func TestDetect(t *testing.T) {
+	trans := transaction.Transactions{
+		{Account: "IRA", Date: date("01/10/2024"), Action: "Buy", Symbol: "NVDA", Quantity: 10},
+		{Account: "IRA", Date: date("03/01/2024"), Action: "Buy", Symbol: "NVDA", Quantity: 5},
+		{Account: "IRA", Date: date("04/01/2024"), Action: "Sell", Symbol: "NVDA", Quantity: 3},
+		{Account: "IRA", Date: date("06/10/2024"), Action: "Stock Split", Symbol: "NVDA", Quantity: 108},
+		{Account: "IRA", Date: date("06/10/2024"), Action: "Options Frwd Split", Symbol: "NVDA 06/21/2024 100.00 C", Quantity: 1},
+		{Account: "IRA", Date: date("07/01/2024"), Action: "Buy", Symbol: "AVGO", Quantity: 2},
+		{Account: "IRA", Date: date("07/15/2024"), Action: "Options Frwd Split", Symbol: "AVGO 08/16/2024 150.00 P", Quantity: 1},
+	}
+
+	proposals := split.Detect(trans, nil)
+	if len(proposals) != 2 {
+		t.Fatalf("expected 2 proposals, got %+v", proposals)
+	}
+	if proposals[0].Symbol != "NVDA" || proposals[0].Numerator != 10 || proposals[0].Denominator != 1 || !proposals[0].Date.Equal(date("06/10/2024")) {
+		t.Errorf("expected NVDA 10 for 1 on 06/10/2024, got %+v", proposals[0])
+	}
+	if proposals[1].Symbol != "AVGO" || proposals[1].Numerator != 0 {
+		t.Errorf("expected an AVGO proposal without a ratio, got %+v", proposals[1])
+	}
+	for _, p := range proposals {
+		if p.Status != split.StatusProposed {
+			t.Errorf("expected status %s, got %s", split.StatusProposed, p.Status)
+		}
+	}
+
+	// known splits, confirmed or rejected, are not proposed again
+	known := split.Splits{
+		{Symbol: "NVDA", Date: date("06/07/2024"), Numerator: 10, Denominator: 1, Status: split.StatusConfirmed},
+		{Symbol: "AVGO", Date: date("07/15/2024"), Status: split.StatusRejected},
+	}
+	if proposals := split.Detect(trans, known); len(proposals) != 0 {
+		t.Errorf("expected no proposals, got %+v", proposals)
+	}
+}
+
+func TestConfirmed(t *testing.T) {
+	splits := split.Splits{
+		{Symbol: "TSLA", Date: date("08/31/2020"), Numerator: 5, Denominator: 1, Status: split.StatusConfirmed},
+		{UserID: 1, Symbol: "TSLA", Date: date("08/31/2020"), Numerator: 4, Denominator: 1, Status: split.StatusConfirmed},
+		{UserID: 1, Symbol: "NVDA", Date: date("06/10/2024"), Numerator: 10, Denominator: 1, Status: split.StatusProposed},
+	}
+	confirmed := splits.Confirmed()
+	if len(confirmed) != 1 {
+		t.Fatalf("expected 1 confirmed split, got %+v", confirmed)
+	}
+	if confirmed[0].Numerator != 4 {
+		t.Errorf("expected the user's split to replace the shared one, got ratio %d/%d", confirmed[0].Numerator, confirmed[0].Denominator)
+	}
+}
+
+func TestDetectRatios(t *testing.T) {
+	tests := []struct {
+		name        string
+		trans       transaction.Transactions
+		numerator   int
+		denominator int
+	}{
+		{
+			name: "three for two",
+			trans: transaction.Transactions{
+				{Account: "IRA", Date: date("01/10/2024"), Action: "Buy", Symbol: "KO", Quantity: 40},
+				{Account: "IRA", Date: date("06/10/2024"), Action: "Stock Split", Symbol: "KO", Quantity: 20},
+			},
+			numerator:   3,
+			denominator: 2,
+		},
+		{
+			name: "reverse",
+			trans: transaction.Transactions{
+				{Account: "IRA", Date: date("01/10/2024"), Action: "Buy", Symbol: "GE", Quantity: 800},
+				{Account: "IRA", Date: date("06/10/2024"), Action: "Reverse Split", Symbol: "GE", Quantity: -800},
+				{Account: "IRA", Date: date("06/10/2024"), Action: "Reverse Split", Symbol: "GE", Quantity: 100},
+			},
+			numerator:   1,
+			denominator: 8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proposals := split.Detect(tt.trans, nil)
+			if len(proposals) != 1 {
+				t.Fatalf("expected 1 proposal, got %+v", proposals)
+			}
+			if proposals[0].Numerator != tt.numerator || proposals[0].Denominator != tt.denominator {
+				t.Errorf("expected %d/%d, got %d/%d", tt.numerator, tt.denominator,
+					proposals[0].Numerator, proposals[0].Denominator)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package split
+
+import (
+	"errors"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"gorm.io/gorm"
+)
+
+type SplitIDDoesNotExistError struct{}
+
+func (*SplitIDDoesNotExistError) Error() string {
+	return "split by id does not exist"
+}
+
+// FindByID returns one of the user's splits or a shared one.
+func FindByID(db *gorm.DB, userID uint, id uint) (*Split, error) {
+	var s Split
+	res := db.Where("id = ? AND user_id IN ?", id, []uint{0, userID}).First(&s)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, &SplitIDDoesNotExistError{}
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return &s, nil
+}
+
+// FindAllForUser returns the shared splits and the user's own, whatever
+// their status.
+func FindAllForUser(db *gorm.DB, userID uint) (Splits, error) {
+	var splits []Split
+	res := db.Where("user_id IN ?", []uint{0, userID}).Order("symbol, date").Find(&splits)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return splits, nil
+}
+
+// FindConfirmed returns the splits to apply to the user's transactions.
+func FindConfirmed(db *gorm.DB, userID uint) (transaction.Splits, error) {
+	splits, err := FindAllForUser(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	return splits.Confirmed(), nil
+}
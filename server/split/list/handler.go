@@ -0,0 +1,25 @@
+package list
+
+import (
+	"github.com/wazupwiddat/postrack/server/split"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User *user.User
+}
+
+type Response struct {
+	Splits split.Splits
+}
+
+func List(db *gorm.DB, req *Request) (*Response, error) {
+	splits, err := split.FindAllForUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Splits: splits,
+	}, nil
+}
@@ -0,0 +1,134 @@
+package split
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusProposed  = "proposed"
+	StatusConfirmed = "confirmed"
+	StatusRejected  = "rejected"
+)
+
+var Statuses = []string{StatusProposed, StatusConfirmed, StatusRejected}
+
+// Split is a stock split.  Quantities and option strikes of transactions
+// dated before it are adjusted once it is confirmed.  Splits without a user
+// are shared by everyone and only admins can change them.
+type Split struct {
+	gorm.Model
+	ID     uint      `gorm:"primary_key"`
+	UserID uint      `gorm:"index"`
+	Symbol string    `gorm:"size:20;index"`
+	Date   time.Time `gorm:"type:date"`
+	// Numerator new shares for every Denominator old ones, see
+	// transaction.Split.
+	Numerator   int
+	Denominator int
+	Status      string `gorm:"size:20"`
+}
+
+type Splits []Split
+
+type InvalidStatusError struct {
+	Status string
+}
+
+func (e *InvalidStatusError) Error() string {
+	return fmt.Sprintf("Invalid split status '%s'", e.Status)
+}
+
+type InvalidRatioError struct {
+	Numerator   int
+	Denominator int
+}
+
+func (e *InvalidRatioError) Error() string {
+	return fmt.Sprintf("Invalid split ratio %d/%d, a confirmed split needs a positive ratio other than 1", e.Numerator, e.Denominator)
+}
+
+type SharedSplitError struct{}
+
+func (*SharedSplitError) Error() string {
+	return "only admins can change shared splits"
+}
+
+func ValidStatus(status string) bool {
+	for _, s := range Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// BeforeSave stores the date as a calendar day, see transaction.StorageDay.
+func (s *Split) BeforeSave(tx *gorm.DB) error {
+	s.Date = transaction.StorageDay(s.Date)
+	return nil
+}
+
+// Validate checks the status and that a confirmed split has a ratio; a
+// proposal may not know its ratio yet.
+func (s Split) Validate() error {
+	if !ValidStatus(s.Status) {
+		return &InvalidStatusError{Status: s.Status}
+	}
+	invalid := s.Numerator < 0 || s.Denominator < 0
+	if s.Status == StatusConfirmed {
+		invalid = invalid || s.Numerator == 0 || s.Denominator == 0 || s.Numerator == s.Denominator
+	}
+	if invalid {
+		return &InvalidRatioError{Numerator: s.Numerator, Denominator: s.Denominator}
+	}
+	return nil
+}
+
+// Confirmed returns the confirmed splits in the form MergeTransactions takes.
+// A user's own split replaces a shared one of the same symbol and date.
+func (s Splits) Confirmed() transaction.Splits {
+	splits := transaction.Splits{}
+	index := map[string]int{}
+	for _, sp := range s {
+		if sp.Status != StatusConfirmed {
+			continue
+		}
+		ts := transaction.Split{
+			Symbol:      sp.Symbol,
+			Date:        transaction.Day(sp.Date),
+			Numerator:   sp.Numerator,
+			Denominator: sp.Denominator,
+		}
+		key := sp.Symbol + sp.Date.Format("2006-01-02")
+		if idx, ok := index[key]; ok {
+			if sp.UserID != 0 {
+				splits[idx] = ts
+			}
+			continue
+		}
+		index[key] = len(splits)
+		splits = append(splits, ts)
+	}
+	return splits
+}
+
+// Near returns the split of symbol within a week of date, if any.
+func (s Splits) Near(symbol string, date time.Time) *Split {
+	for idx, sp := range s {
+		if sp.Symbol != symbol {
+			continue
+		}
+		diff := sp.Date.Sub(date)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= 7*24*time.Hour {
+			return &s[idx]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package modify
+
+import (
+	"strings"
+	"time"
+
+	"github.com/wazupwiddat/postrack/server/split"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User        *user.User
+	ID          uint
+	Symbol      string
+	Date        time.Time
+	Numerator   int
+	Denominator int
+	Status      string
+}
+
+type Response struct {
+	Split *split.Split
+}
+
+// ModifySplit changes a split, which is also how a proposed split is
+// confirmed or rejected.
+func ModifySplit(db *gorm.DB, req *Request) (*Response, error) {
+	s, err := split.FindByID(db, req.User.ID, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if s.UserID == 0 && !req.User.Admin {
+		return nil, &split.SharedSplitError{}
+	}
+	s.Symbol = strings.ToUpper(req.Symbol)
+	s.Date = req.Date
+	s.Numerator = req.Numerator
+	s.Denominator = req.Denominator
+	s.Status = req.Status
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	_, err = split.Update(db, s)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Split: s,
+	}, nil
+}
@@ -0,0 +1,23 @@
+package remove
+
+import (
+	"github.com/wazupwiddat/postrack/server/split"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type Request struct {
+	User *user.User
+	ID   uint
+}
+
+func RemoveSplit(db *gorm.DB, req *Request) error {
+	s, err := split.FindByID(db, req.User.ID, req.ID)
+	if err != nil {
+		return err
+	}
+	if s.UserID == 0 && !req.User.Admin {
+		return &split.SharedSplitError{}
+	}
+	return split.Delete(db, s)
+}
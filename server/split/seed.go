@@ -0,0 +1,32 @@
+package split
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// seedSplits are shared splits every install starts with; they used to be
+// hard coded in MergeTransactions.
+var seedSplits = []Split{
+	{Symbol: "TSLA", Date: time.Date(2020, 8, 31, 0, 0, 0, 0, time.UTC), Numerator: 5, Denominator: 1, Status: StatusConfirmed},
+	{Symbol: "TSLA", Date: time.Date(2022, 8, 25, 0, 0, 0, 0, time.UTC), Numerator: 3, Denominator: 1, Status: StatusConfirmed},
+}
+
+// Seed adds the shared splits that are missing.
+func Seed(db *gorm.DB) error {
+	shared, err := FindAllForUser(db, 0)
+	if err != nil {
+		return err
+	}
+	for _, s := range seedSplits {
+		if shared.Near(s.Symbol, s.Date) != nil {
+			continue
+		}
+		s := s
+		if _, err := Create(db, &s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package transaction
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ActionType is what a transaction does, whatever words the broker used for
+// it.  The broker's text is kept in Action.
+type ActionType string
+
+const (
+	ActionUnknown      ActionType = ""
+	ActionBuy          ActionType = "buy"
+	ActionSell         ActionType = "sell"
+	ActionBuyToOpen    ActionType = "buy_to_open"
+	ActionSellToOpen   ActionType = "sell_to_open"
+	ActionBuyToClose   ActionType = "buy_to_close"
+	ActionSellToClose  ActionType = "sell_to_close"
+	ActionAssigned     ActionType = "assigned"
+	ActionExpired      ActionType = "expired"
+	ActionExercised    ActionType = "exercised"
+	ActionReinvest     ActionType = "reinvest"
+	ActionDividend     ActionType = "dividend"
+	ActionInterest     ActionType = "interest"
+	ActionFee          ActionType = "fee"
+	ActionTax          ActionType = "tax"
+	ActionCash         ActionType = "cash"
+	ActionTransfer     ActionType = "transfer"
+	ActionJournal      ActionType = "journal"
+	ActionSplit        ActionType = "split"
+	ActionReverseSplit ActionType = "reverse_split"
+	ActionCashInLieu   ActionType = "cash_in_lieu"
+	// ActionCorporate is a broker's row for a merger, spinoff or symbol
+	// change, which a known corporate action replaces.
+	ActionCorporate ActionType = "corporate"
+)
+
+// brokerActions is the broker text, lower cased, of every action postrack
+// knows.  The importers of other brokers translate to the Schwab text.
+var brokerActions = map[string]ActionType{
+	"buy":                  ActionBuy,
+	"sell":                 ActionSell,
+	"sell short":           ActionSell,
+	"buy to open":          ActionBuyToOpen,
+	"sell to open":         ActionSellToOpen,
+	"buy to close":         ActionBuyToClose,
+	"buy to cover":         ActionBuyToClose,
+	"sell to close":        ActionSellToClose,
+	"assigned":             ActionAssigned,
+	"expired":              ActionExpired,
+	"exchange or exercise": ActionExercised,
+	"reinvest shares":      ActionReinvest,
+	"cash dividend":        ActionDividend,
+	"qualified dividend":   ActionDividend,
+	"non-qualified div":    ActionDividend,
+	"special dividend":     ActionDividend,
+	"pr yr cash div":       ActionDividend,
+	"reinvest dividend":    ActionDividend,
+	"qual div reinvest":    ActionDividend,
+	"pr yr div reinvest":   ActionDividend,
+	"long term cap gain":   ActionDividend,
+	"short term cap gain":  ActionDividend,
+	"credit interest":      ActionInterest,
+	"bank interest":        ActionInterest,
+	"bond interest":        ActionInterest,
+	"margin interest":      ActionInterest,
+	"service fee":          ActionFee,
+	"adr mgmt fee":         ActionFee,
+	"foreign tax paid":     ActionTax,
+	"nra tax adj":          ActionTax,
+	"misc cash entry":      ActionCash,
+	"merger cash":          ActionCash,
+	"return of capital":    ActionCash,
+	"moneylink transfer":   ActionTransfer,
+	"moneylink deposit":    ActionTransfer,
+	"wire funds":           ActionTransfer,
+	"wire funds received":  ActionTransfer,
+	"funds received":       ActionTransfer,
+	"internal transfer":    ActionTransfer,
+	"security transfer":    ActionTransfer,
+	"journal":              ActionJournal,
+	"journaled shares":     ActionJournal,
+	"stock split":          ActionSplit,
+	"reverse split":        ActionReverseSplit,
+	"options frwd split":   ActionSplit,
+	"cash in lieu":         ActionCashInLieu,
+	"corporate action":     ActionCorporate,
+	"cash merger":          ActionCorporate,
+	"stock merger":         ActionCorporate,
+	"merger":               ActionCorporate,
+	"spin-off":             ActionCorporate,
+	"spinoff":              ActionCorporate,
+	"symbol change":        ActionCorporate,
+	"name change":          ActionCorporate,
+	"ticker change":        ActionCorporate,
+}
+
+type UnknownActionError struct {
+	Action string
+}
+
+func (e *UnknownActionError) Error() string {
+	return fmt.Sprintf("unknown action %q", e.Action)
+}
+
+// ParseAction returns the type of the broker's action text.
+func ParseAction(action string) (ActionType, error) {
+	a, ok := brokerActions[strings.ToLower(strings.TrimSpace(action))]
+	if !ok {
+		return ActionUnknown, &UnknownActionError{Action: action}
+	}
+	return a, nil
+}
+
+// Classify sets the type of the transaction from its action text, and the
+// contract of an option from its symbol when the importer has not.
+func (t *Transaction) Classify() error {
+	a, err := ParseAction(t.Action)
+	if err != nil {
+		return err
+	}
+	t.Type = a
+	if t.Option == nil {
+		if o, ok := ParseOption(t.Symbol); ok {
+			t.Option = &o
+		}
+	}
+	return nil
+}
+
+// ActionType is the type of the transaction.  Rows built without one, such
+// as the ones stored before types were, are typed from their action text.
+func (t Transaction) ActionType() ActionType {
+	if t.Type != ActionUnknown {
+		return t.Type
+	}
+	a, _ := ParseAction(t.Action)
+	return a
+}
+
+// Long reports whether the action adds to the quantity held.  Closing a
+// short option by assignment or expiry counts as buying it back.
+func (a ActionType) Long() bool {
+	switch a {
+	case ActionBuy, ActionBuyToOpen, ActionBuyToClose, ActionAssigned, ActionExpired, ActionReinvest:
+		return true
+	}
+	return false
+}
+
+// MigrateActionTypes types the stored transactions that have no type yet.
+// Rows whose action is unknown are left untyped, which keeps them out of
+// positions, and are logged.
+func MigrateActionTypes(db *gorm.DB) error {
+	var actions []string
+	err := db.Table("transactions").Where("type = ?", ActionUnknown).Distinct().Pluck("action", &actions).Error
+	if err != nil {
+		return err
+	}
+	for _, action := range actions {
+		a, err := ParseAction(action)
+		if err != nil {
+			var count int64
+			db.Table("transactions").Where("type = ? AND action = ?", ActionUnknown, action).Count(&count)
+			log.Printf("%d transactions have an unknown action %q\n", count, action)
+			continue
+		}
+		err = db.Table("transactions").Where("type = ? AND action = ?", ActionUnknown, action).Update("type", a).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package transaction
+
+import (
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// CorporateRename moves Symbol's history to NewSymbol, share for share.
+	CorporateRename = "rename"
+	// CorporateMerger turns every Denominator shares of Symbol into
+	// Numerator shares of NewSymbol plus Cash per share.  With no NewSymbol
+	// it is a cash merger that closes the position at Cash a share.
+	CorporateMerger = "merger"
+	// CorporateSpinoff gives Numerator shares of NewSymbol for every
+	// Denominator shares of Symbol held, and moves BasisPercent of the
+	// parent's cost to them.
+	CorporateSpinoff = "spinoff"
+)
+
+type CorporateAction struct {
+	Type         string
+	Symbol       string
+	NewSymbol    string
+	Date         time.Time
+	Numerator    int
+	Denominator  int
+	Cash         decimal.Decimal
+	BasisPercent float64
+}
+type CorporateActions []CorporateAction
+
+// Factor is the new shares per old one.
+func (a CorporateAction) Factor() float64 {
+	if a.Denominator == 0 {
+		return float64(a.Numerator)
+	}
+	return float64(a.Numerator) / float64(a.Denominator)
+}
+
+// Predecessors returns symbol and every symbol whose history carries into
+// it through the actions.
+func (c CorporateActions) Predecessors(symbol string) []string {
+	symbols := []string{symbol}
+	found := map[string]bool{symbol: true}
+	for added := true; added; {
+		added = false
+		for _, a := range c {
+			if found[a.NewSymbol] && !found[a.Symbol] {
+				found[a.Symbol] = true
+				symbols = append(symbols, a.Symbol)
+				added = true
+			}
+		}
+	}
+	return symbols
+}
+
+// Adjust applies the splits and corporate actions in date order, so that a
+// split of a renamed symbol also adjusts the rows from before the rename.
+func (t *Transactions) Adjust(splits Splits, actions CorporateActions) *Transactions {
+	type event struct {
+		date   time.Time
+		split  *Split
+		action *CorporateAction
+	}
+	events := []event{}
+	for idx := range splits {
+		events = append(events, event{date: splits[idx].Date, split: &splits[idx]})
+	}
+	for idx := range actions {
+		events = append(events, event{date: actions[idx].Date, action: &actions[idx]})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].date.Before(events[j].date)
+	})
+	for _, e := range events {
+		if e.split != nil {
+			t.ApplySplits(Splits{*e.split})
+			continue
+		}
+		t.applyCorporateAction(*e.action)
+	}
+	return t
+}
+
+// ApplyCorporateActions rewrites the transactions from before each action
+// in terms of what they became, and adds the rows the action itself made.
+func (t *Transactions) ApplyCorporateActions(actions CorporateActions) *Transactions {
+	return t.Adjust(nil, actions)
+}
+
+type holding struct {
+	userID   uint
+	quantity float64
+	amount   decimal.Decimal
+}
+
+func (t *Transactions) applyCorporateAction(a CorporateAction) {
+	holdings := t.stockHoldings(a.Symbol, a.Date)
+	accounts := []string{}
+	for account := range holdings {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	trans := Transactions{}
+	for _, tran := range *t {
+		if isBrokerCorporateActionRow(tran, a) {
+			continue
+		}
+		if SymbolFromOptionSymbol(tran.Symbol) != a.Symbol || !tran.Date.Before(a.Date) {
+			trans = append(trans, tran)
+			continue
+		}
+		switch {
+		case a.Type == CorporateRename:
+			renameSymbol(&tran, a.NewSymbol)
+		case a.Type == CorporateMerger && a.NewSymbol != "" && a.Numerator > 0:
+			renameSymbol(&tran, a.NewSymbol)
+			if o, ok := tran.Contract(); ok {
+				o.Deliverable = o.SharesPerContract() * a.Factor()
+				tran.SetContract(o)
+			} else {
+				tran.Quantity = tran.Quantity * a.Factor()
+			}
+		}
+		trans = append(trans, tran)
+	}
+
+	for _, account := range accounts {
+		h := holdings[account]
+		if h.quantity <= 0 {
+			continue
+		}
+		row := Transaction{UserID: h.userID, Account: account, Date: a.Date}
+		switch {
+		case a.Type == CorporateMerger && (a.NewSymbol == "" || a.Numerator == 0):
+			row.Action = "Sell"
+			row.Type = ActionSell
+			row.Symbol = a.Symbol
+			row.Description = "CASH MERGER"
+			row.Quantity = h.quantity
+			row.Price = a.Cash
+			row.Amount = a.Cash.Mul(decimal.NewFromFloat(h.quantity)).Round(2)
+			trans = append(trans, row)
+		case a.Type == CorporateMerger && a.Cash.IsPositive():
+			row.Action = "Merger Cash"
+			row.Type = ActionCash
+			row.Symbol = a.NewSymbol
+			row.Description = "CASH PORTION OF MERGER WITH " + a.Symbol
+			row.Amount = a.Cash.Mul(decimal.NewFromFloat(h.quantity)).Round(2)
+			trans = append(trans, row)
+		case a.Type == CorporateSpinoff:
+			// the cost moved is part of what was paid for the parent, so it
+			// is as negative as the parent's amount
+			moved := h.amount.Mul(decimal.NewFromFloat(a.BasisPercent)).Div(decimal.NewFromInt(100)).Round(2)
+			child := row
+			child.Action = "Buy"
+			child.Type = ActionBuy
+			child.Symbol = a.NewSymbol
+			child.Description = "SPINOFF FROM " + a.Symbol
+			child.Quantity = h.quantity * a.Factor()
+			child.Amount = moved
+			row.Action = "Spin-off"
+			row.Type = ActionCorporate
+			row.Symbol = a.Symbol
+			row.Description = "COST MOVED TO " + a.NewSymbol
+			row.Amount = moved.Neg()
+			trans = append(trans, row, child)
+		}
+	}
+	*t = trans
+}
+
+// stockHoldings is the shares of symbol, and what they cost, held in each
+// account before date.
+func (t *Transactions) stockHoldings(symbol string, date time.Time) map[string]*holding {
+	holdings := map[string]*holding{}
+	for _, tran := range *t {
+		if tran.Symbol != symbol {
+			continue
+		}
+		if !tran.Date.Before(date) {
+			continue
+		}
+		h, ok := holdings[tran.Account]
+		if !ok {
+			h = &holding{userID: tran.UserID}
+			holdings[tran.Account] = h
+		}
+		if getDirection(tran) == dirLong {
+			h.quantity += tran.Quantity
+		} else {
+			h.quantity -= tran.Quantity
+		}
+		h.amount = h.amount.Add(tran.Amount)
+	}
+	return holdings
+}
+
+// isBrokerCorporateActionRow reports whether tran is the broker's own row
+// for the action.  They are dropped around an action that is known, whose
+// rows replace them.
+func isBrokerCorporateActionRow(tran Transaction, a CorporateAction) bool {
+	sym := SymbolFromOptionSymbol(tran.Symbol)
+	if sym != a.Symbol && sym != a.NewSymbol {
+		return false
+	}
+	diff := tran.Date.Sub(a.Date)
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > 7*24*time.Hour {
+		return false
+	}
+	return tran.ActionType() == ActionCorporate
+}
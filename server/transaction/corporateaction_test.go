@@ -0,0 +1,116 @@
+package transaction_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/transaction"
+)
+
+func positionOf(t *testing.T, positions transaction.Positions, symbol string) transaction.Position {
+	t.Helper()
+	for _, pos := range positions {
+		if pos.Symbol == symbol {
+			return pos
+		}
+	}
+	t.Fatalf("no position in %s", symbol)
+	return transaction.Position{}
+}
+
+func TestCorporateActions(t *testing.T) {
+	actionDate := time.Date(2022, 6, 9, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		action   transaction.CorporateAction
+		given    transaction.Transactions
+		expected map[string][2]float64 // symbol: quantity, amount
+	}{
+		{
+			name:   "rename",
+			action: transaction.CorporateAction{Type: transaction.CorporateRename, Symbol: "FB", NewSymbol: "META", Date: actionDate},
+			given: transaction.Transactions{
+				{Account: "A", Symbol: "FB", Date: date("01/10/2022"), Action: "Buy", Quantity: 10, Amount: dec("-3000")},
+				{Account: "A", Symbol: "FB 07/15/2022 250.00 C", Date: date("05/10/2022"), Action: "Sell to Open", Quantity: 1, Amount: dec("200")},
+				{Account: "A", Symbol: "META", Date: date("06/09/2022"), Action: "Symbol Change", Quantity: 10},
+				{Account: "A", Symbol: "META", Date: date("08/10/2022"), Action: "Sell", Quantity: 4, Amount: dec("700")},
+			},
+			expected: map[string][2]float64{
+				"META":                     {6, -2300},
+				"META 07/15/2022 250.00 C": {-1, 200},
+			},
+		},
+		{
+			name:   "cash merger",
+			action: transaction.CorporateAction{Type: transaction.CorporateMerger, Symbol: "TWTR", Cash: dec("54.20"), Date: actionDate},
+			given: transaction.Transactions{
+				{Account: "A", Symbol: "TWTR", Date: date("01/10/2022"), Action: "Buy", Quantity: 100, Amount: dec("-4000")},
+				{Account: "A", Symbol: "TWTR", Date: date("06/09/2022"), Action: "Cash Merger", Quantity: 100, Amount: dec("5420")},
+			},
+			expected: map[string][2]float64{
+				"TWTR": {0, 1420},
+			},
+		},
+		{
+			name:   "stock and cash merger",
+			action: transaction.CorporateAction{Type: transaction.CorporateMerger, Symbol: "ATVI", NewSymbol: "MSFT", Numerator: 1, Denominator: 4, Cash: dec("2"), Date: actionDate},
+			given: transaction.Transactions{
+				{Account: "A", Symbol: "ATVI", Date: date("01/10/2022"), Action: "Buy", Quantity: 100, Amount: dec("-8000")},
+				{Account: "A", Symbol: "MSFT", Date: date("06/09/2022"), Action: "Stock Merger", Quantity: 25},
+			},
+			expected: map[string][2]float64{
+				"MSFT": {25, -7800},
+			},
+		},
+		{
+			name:   "spinoff",
+			action: transaction.CorporateAction{Type: transaction.CorporateSpinoff, Symbol: "GE", NewSymbol: "GEHC", Numerator: 1, Denominator: 3, BasisPercent: 25, Date: actionDate},
+			given: transaction.Transactions{
+				{Account: "A", Symbol: "GE", Date: date("01/10/2022"), Action: "Buy", Quantity: 300, Amount: dec("-24000")},
+				{Account: "A", Symbol: "GEHC", Date: date("06/09/2022"), Action: "Spin-off", Quantity: 100},
+			},
+			expected: map[string][2]float64{
+				"GE":   {300, -18000},
+				"GEHC": {100, -6000},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			positions := tt.given.MergeTransactions(nil, transaction.CorporateActions{tt.action}).CollectPositions()
+			if len(positions) != len(tt.expected) {
+				t.Fatalf("Expected %d positions but got %v", len(tt.expected), positions)
+			}
+			for symbol, expected := range tt.expected {
+				pos := positionOf(t, positions, symbol)
+				if math.Abs(pos.Quantity-expected[0]) > 0.000001 || !pos.Amount.Equal(decimal.NewFromFloat(expected[1])) {
+					t.Errorf("Expected %s quantity %v amount %v but got %v %v",
+						symbol, expected[0], expected[1], pos.Quantity, pos.Amount)
+				}
+			}
+		})
+	}
+}
+
+func TestAdjustSplitAfterRename(t *testing.T) {
+	trans := transaction.Transactions{
+		{Account: "A", Symbol: "FB", Date: date("01/10/2022"), Action: "Buy", Quantity: 10},
+	}
+	splits := transaction.Splits{
+		{Symbol: "META", Date: time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC), Numerator: 2, Denominator: 1},
+	}
+	actions := transaction.CorporateActions{
+		{Type: transaction.CorporateRename, Symbol: "FB", NewSymbol: "META", Date: time.Date(2022, 6, 9, 0, 0, 0, 0, time.UTC)},
+	}
+	result := *trans.Adjust(splits, actions)
+	if result[0].Symbol != "META" || result[0].Quantity != 20 {
+		t.Errorf("Expected 20 META but got %v %v", result[0].Quantity, result[0].Symbol)
+	}
+
+	predecessors := actions.Predecessors("META")
+	if len(predecessors) != 2 || predecessors[1] != "FB" {
+		t.Errorf("Expected META and FB but got %v", predecessors)
+	}
+}
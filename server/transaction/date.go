@@ -0,0 +1,108 @@
+package transaction
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DateLayout is how brokers write trade dates, and how option symbols carry
+// their expiry.
+const DateLayout = "01/02/2006"
+
+// ParseDate reads a trade date written like 01/02/2006.
+func ParseDate(value string) (time.Time, error) {
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return t, nil
+}
+
+// Day is the calendar day of t as UTC midnight, which is how trade dates
+// are compared.
+func Day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// StorageDay is the calendar day of t as midnight in the local time zone.
+// The database connection converts times to local time before a DATE
+// column keeps the date part, so a UTC midnight saved as is can move a day;
+// every model with a DATE column saves it through StorageDay and reads it
+// back through Day.
+func StorageDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// BeforeSave stores the trade date and option expiry as calendar days.
+func (t *Transaction) BeforeSave(tx *gorm.DB) error {
+	t.Date = StorageDay(t.Date)
+	if t.Option != nil {
+		t.Option.Expiry = StorageDay(t.Option.Expiry)
+	}
+	return nil
+}
+
+// AfterSave leaves the caller with the trade date it saved.
+func (t *Transaction) AfterSave(tx *gorm.DB) error {
+	t.readDays()
+	return nil
+}
+
+// AfterFind reads trade dates back as UTC midnight.
+func (t *Transaction) AfterFind(tx *gorm.DB) error {
+	t.readDays()
+	return nil
+}
+
+func (t *Transaction) readDays() {
+	t.Date = Day(t.Date)
+	if t.Option == nil {
+		return
+	}
+	// stock rows read back with an empty contract
+	if t.Option.Root == "" {
+		t.Option = nil
+		return
+	}
+	t.Option.Expiry = Day(t.Option.Expiry)
+}
+
+// MigrateDates rewrites trade dates stored as 01/02/2006 text as
+// 2006-01-02, which AutoMigrate can then turn into a DATE column.  It must
+// run before AutoMigrate and fails, naming the rows, when a stored date
+// cannot be read.
+func MigrateDates(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&Transaction{}) {
+		return nil
+	}
+	type storedDate struct {
+		ID   uint
+		Date string
+	}
+	var rows []storedDate
+	err := db.Table("transactions").Select("id, date").Where("date LIKE ?", "%/%").Find(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	invalid := []uint{}
+	for _, row := range rows {
+		t, err := ParseDate(row.Date)
+		if err != nil {
+			invalid = append(invalid, row.ID)
+			continue
+		}
+		err = db.Table("transactions").Where("id = ?", row.ID).Update("date", t.Format("2006-01-02")).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("transactions %v have dates that cannot be read, fix them before starting", invalid)
+	}
+	return nil
+}
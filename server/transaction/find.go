@@ -1,10 +1,31 @@
 package transaction
 
 import (
+	"errors"
+	"time"
+
 	"github.com/wazupwiddat/postrack/server/user"
 	"gorm.io/gorm"
 )
 
+type TransactionIDDoesNotExistError struct{}
+
+func (*TransactionIDDoesNotExistError) Error() string {
+	return "transaction by id does not exist"
+}
+
+func FindByID(db *gorm.DB, userID uint, id uint) (*Transaction, error) {
+	var t Transaction
+	res := db.Where("id = ? AND user_id = ?", id, userID).First(&t)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, &TransactionIDDoesNotExistError{}
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return &t, nil
+}
+
 func FindAllByUser(db *gorm.DB, u *user.User) ([]Transaction, error) {
 	var transactions []Transaction
 	res := db.Find(&transactions, &Transaction{UserID: u.ID})
@@ -14,6 +35,19 @@ func FindAllByUser(db *gorm.DB, u *user.User) ([]Transaction, error) {
 	return transactions, nil
 }
 
+// FindAllByUserBetween returns the user's transactions dated from start to
+// end, both days included, in the order they were stored.
+func FindAllByUserBetween(db *gorm.DB, u *user.User, start time.Time, end time.Time) ([]Transaction, error) {
+	var transactions []Transaction
+	res := db.Where("user_id = ? AND date BETWEEN ? AND ?", u.ID, StorageDay(start), StorageDay(end)).
+		Order("id").
+		Find(&transactions)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return transactions, nil
+}
+
 func FindAllByUserPaginated(db *gorm.DB, u *user.User, page int, pageSize int) ([]Transaction, error) {
 	var transactions []Transaction
 	offset := (page - 1) * pageSize
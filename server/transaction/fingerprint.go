@@ -0,0 +1,41 @@
+package transaction
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Fingerprint returns a stable identifier for a broker row.  Identical rows
+// (e.g. two fills of the same size on the same day) are told apart by the
+// occurrence index, which counts how many identical rows came before it.
+func Fingerprint(tran Transaction, occurrence int) string {
+	h := md5.New()
+	io.WriteString(h, fingerprintContent(tran))
+	io.WriteString(h, strconv.Itoa(occurrence))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func (t *Transactions) WithFingerprints() *Transactions {
+	occurrences := map[string]int{}
+	trans := Transactions{}
+	for _, tran := range *t {
+		content := fingerprintContent(tran)
+		tran.Fingerprint = Fingerprint(tran, occurrences[content])
+		occurrences[content]++
+		trans = append(trans, tran)
+	}
+	return &trans
+}
+
+func fingerprintContent(tran Transaction) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s|",
+		tran.Account,
+		tran.Date.Format(DateLayout),
+		tran.Action,
+		tran.Symbol,
+		strconv.FormatFloat(tran.Quantity, 'f', -1, 64),
+		tran.Price.String(),
+		tran.Amount.String())
+}
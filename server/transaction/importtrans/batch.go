@@ -0,0 +1,49 @@
+package importtrans
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/wazupwiddat/postrack/server/importbatch"
+	"github.com/wazupwiddat/postrack/server/split"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+func startBatch(db *gorm.DB, u *user.User, files []os.FileInfo) (*importbatch.ImportBatch, error) {
+	names := []string{}
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	batch := &importbatch.ImportBatch{
+		UserID: u.ID,
+		Files:  strings.Join(names, ","),
+	}
+	if _, err := importbatch.Create(db, batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// proposeSplits looks for splits in what the user has imported so far.  A
+// failure here does not fail the import.
+func proposeSplits(db *gorm.DB, u *user.User) {
+	proposals, err := split.Propose(db, u)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, s := range proposals {
+		log.Printf("Proposed split for User %d: %s %s ratio %d/%d\n", u.ID, s.Symbol, s.Date.Format("01/02/2006"), s.Numerator, s.Denominator)
+	}
+}
+
+func finishBatch(db *gorm.DB, batch *importbatch.ImportBatch, result *MergeResult) {
+	batch.Inserted = result.Inserted
+	batch.Skipped = result.Skipped
+	batch.Conflicting = result.Conflicting
+	if _, err := importbatch.Update(db, batch); err != nil {
+		log.Println(err)
+	}
+}
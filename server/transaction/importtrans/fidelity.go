@@ -0,0 +1,202 @@
+package importtrans
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/transaction"
+)
+
+const FormatFidelityCSV = "fidelity-csv"
+
+type fidelityImporter struct{}
+
+func init() {
+	Register(fidelityImporter{})
+}
+
+func (fidelityImporter) Format() string {
+	return FormatFidelityCSV
+}
+
+// Detect looks for the column header of an "Accounts History" download,
+// which comes after a few blank lines.
+func (fidelityImporter) Detect(header []byte) bool {
+	h := strings.TrimPrefix(string(header), "\ufeff")
+	return strings.Contains(h, "Run Date,") && strings.Contains(h, "Action,")
+}
+
+// Fidelity action text, matched by prefix, to the Schwab action the rest of
+// postrack understands.  Order matters: "YOU SOLD OPENING" before "YOU SOLD".
+var fidelityActions = []struct {
+	prefix string
+	action string
+}{
+	{"YOU SOLD OPENING TRANSACTION", "Sell to Open"},
+	{"YOU BOUGHT OPENING TRANSACTION", "Buy to Open"},
+	{"YOU SOLD CLOSING TRANSACTION", "Sell to Close"},
+	{"YOU BOUGHT CLOSING TRANSACTION", "Buy to Close"},
+	{"ASSIGNED", "Assigned"},
+	{"EXPIRED", "Expired"},
+	{"YOU EXERCISED", "Exchange or Exercise"},
+	{"EXERCISED", "Exchange or Exercise"},
+	{"YOU BOUGHT", "Buy"},
+	{"YOU SOLD", "Sell"},
+	{"REINVESTMENT", "Reinvest Shares"},
+	{"DIVIDEND RECEIVED", "Cash Dividend"},
+	{"INTEREST EARNED", "Credit Interest"},
+}
+
+func (fidelityImporter) Parse(r io.Reader) (*ParseResult, error) {
+	result := &ParseResult{}
+	reader := csv.NewReader(r)
+	// blank lines, the header and the disclaimer at the end vary in length
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var columns map[string]int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				result.Errors = append(result.Errors, RowError{Line: parseErr.StartLine, Message: parseErr.Err.Error()})
+				continue
+			}
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		first := strings.TrimSpace(strings.TrimPrefix(record[0], "\ufeff"))
+		if columns == nil {
+			if first == "Run Date" {
+				columns = fidelityColumns(record)
+			}
+			continue
+		}
+
+		// the disclaimer lines at the end of the download
+		if len(record) < len(columns) {
+			continue
+		}
+
+		// an Accounts History download covers every account
+		account := fidelityField(record, columns, "Account")
+		if result.Account == "" {
+			result.Account = account
+		}
+		t, err := fidelityTransaction(record, columns)
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{Line: line, Message: err.Error()})
+			continue
+		}
+		t.Account = truncate(account, 100)
+		result.Transactions = append(result.Transactions, t)
+	}
+	if columns == nil {
+		return nil, fmt.Errorf("no Run Date header found")
+	}
+	return result, nil
+}
+
+func fidelityColumns(header []string) map[string]int {
+	columns := map[string]int{}
+	for idx, name := range header {
+		name = strings.TrimSpace(name)
+		// "Price ($)" -> "Price"
+		name = strings.TrimSpace(strings.TrimSuffix(name, "($)"))
+		columns[name] = idx
+	}
+	return columns
+}
+
+func fidelityField(record []string, columns map[string]int, names ...string) string {
+	for _, name := range names {
+		if idx, ok := columns[name]; ok && idx < len(record) {
+			return strings.TrimSpace(record[idx])
+		}
+	}
+	return ""
+}
+
+func fidelityTransaction(record []string, columns map[string]int) (transaction.Transaction, error) {
+	date, err := transaction.ParseDate(fidelityField(record, columns, "Run Date"))
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+
+	rawAction := fidelityField(record, columns, "Action")
+	if rawAction == "" {
+		return transaction.Transaction{}, fmt.Errorf("missing action")
+	}
+	action := fidelityAction(rawAction)
+
+	symbol, err := fidelitySymbol(fidelityField(record, columns, "Symbol"))
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+
+	numbers := map[string]decimal.Decimal{}
+	for _, name := range []string{"Quantity", "Price", "Commission", "Fees", "Amount"} {
+		value := fidelityField(record, columns, name)
+		d, err := safeStringToDecimal(value)
+		if err != nil {
+			return transaction.Transaction{}, fmt.Errorf("invalid %s %q", strings.ToLower(name), value)
+		}
+		numbers[name] = d
+	}
+
+	t := transaction.Transaction{
+		Date:        date,
+		Action:      action,
+		Symbol:      symbol,
+		Description: truncate(fidelityField(record, columns, "Security Description", "Description"), 250),
+		// Fidelity signs the quantity, Schwab carries the sign in the action
+		Quantity: numbers["Quantity"].Abs().InexactFloat64(),
+		Price:    numbers["Price"],
+		FeesComm: numbers["Commission"].Add(numbers["Fees"]),
+		Amount:   numbers["Amount"],
+	}
+	if err := t.Classify(); err != nil {
+		return transaction.Transaction{}, err
+	}
+	return t, nil
+}
+
+func fidelityAction(raw string) string {
+	upper := strings.ToUpper(raw)
+	for _, a := range fidelityActions {
+		if strings.HasPrefix(upper, a.prefix) {
+			return a.action
+		}
+	}
+	return truncate(raw, 50)
+}
+
+// fidelitySymbol turns an option symbol such as -AAPL240119C190 into the
+// Schwab layout AAPL 01/19/2024 190.00 C; stock symbols pass through.
+func fidelitySymbol(sym string) (string, error) {
+	sym = strings.ToUpper(strings.TrimSpace(sym))
+	if !strings.HasPrefix(sym, "-") {
+		return sym, nil
+	}
+	o, ok := transaction.ParseOption(sym)
+	if !ok {
+		return "", fmt.Errorf("invalid option symbol %q", sym)
+	}
+	return o.Symbol(), nil
+}
+
+func truncate(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	return s[:size]
+}
@@ -0,0 +1,78 @@
+package importtrans_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/transaction/importtrans"
+)
+
+func TestParseFidelityCSV(t *testing.T) {
+	f, err := os.Open("../../../test_data/fidelityAccountsHistory.csv")
+	if err != nil {
+		t.Fatalf("Unable to open file %s", "./test_data/fidelityAccountsHistory.csv")
+	}
+	defer f.Close()
+
+	imp, r, err := importtrans.DetectReader("fidelityAccountsHistory.csv", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imp.Format() != importtrans.FormatFidelityCSV {
+		t.Fatalf("Expected fidelity importer, got %s", imp.Format())
+	}
+	result, err := imp.Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Account != "ROTH IRA Z12345678" {
+		t.Errorf("Expected the account column, got %q", result.Account)
+	}
+	if len(result.Transactions) != 7 {
+		t.Fatalf("Expected 7 transactions, got %d", len(result.Transactions))
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Line != 11 {
+		t.Errorf("Expected an error on line 11, got %v", result.Errors)
+	}
+
+	expected := []transaction.Transaction{
+		{Date: date("01/22/2024"), Action: "Assigned", Symbol: "AAPL 01/19/2024 190.00 C", Quantity: 1},
+		{Date: date("01/22/2024"), Action: "Sell", Symbol: "AAPL", Quantity: 100, Price: dec("190"), FeesComm: dec("0.03"), Amount: dec("18999.97")},
+		{Date: date("01/19/2024"), Action: "Expired", Symbol: "SPY 01/19/2024 472.50 P", Quantity: 1},
+		{Date: date("01/02/2024"), Action: "Sell to Open", Symbol: "AAPL 01/19/2024 190.00 C", Quantity: 1, Price: dec("1.5"), FeesComm: dec("0.66"), Amount: dec("149.34")},
+		{Date: date("01/02/2024"), Action: "Sell to Open", Symbol: "SPY 01/19/2024 472.50 P", Quantity: 1, Price: dec("2.1"), FeesComm: dec("0.66"), Amount: dec("209.34")},
+		{Date: date("12/15/2023"), Action: "Buy", Symbol: "AAPL", Quantity: 100, Price: dec("185.5"), Amount: dec("-18550")},
+		{Date: date("12/14/2023"), Action: "Cash Dividend", Symbol: "SPAXX", Amount: dec("12.34")},
+	}
+	for i, tran := range result.Transactions {
+		e := expected[i]
+		account := "ROTH IRA Z12345678"
+		if e.Symbol == "SPAXX" {
+			account = "INDIVIDUAL X87654321"
+		}
+		if tran.Account != account {
+			t.Errorf("Expected %v in account %q, got %q", e, account, tran.Account)
+		}
+		if tran.Date != e.Date || tran.Action != e.Action || tran.Symbol != e.Symbol ||
+			tran.Quantity != e.Quantity || !tran.Price.Equal(e.Price) || !tran.FeesComm.Equal(e.FeesComm) ||
+			!tran.Amount.Equal(e.Amount) {
+			t.Errorf("Expected %v but got %v", e, tran)
+		}
+	}
+
+	trans := transaction.Transactions(result.Transactions)
+	positions := trans.MergeTransactions(nil, nil).CollectPositions()
+	for _, pos := range positions {
+		switch pos.Symbol {
+		case "AAPL 01/19/2024 190.00 C":
+			if pos.Disposition != transaction.Disposition(3) {
+				t.Errorf("Expected AAPL call to be assigned")
+			}
+		case "SPY 01/19/2024 472.50 P":
+			if pos.Disposition != transaction.Disposition(2) {
+				t.Errorf("Expected SPY put to be expired")
+			}
+		}
+	}
+}
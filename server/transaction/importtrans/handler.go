@@ -2,18 +2,16 @@ package importtrans
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 
-	"github.com/wazupwiddat/postrack/server/config"
+	"github.com/shopspring/decimal"
 	"github.com/wazupwiddat/postrack/server/transaction"
-	"github.com/wazupwiddat/postrack/server/user"
-	"gorm.io/gorm"
 )
 
 const (
@@ -27,87 +25,141 @@ const (
 	csvAmount
 )
 
-func ImprotUploadedFiles(db *gorm.DB, cfg *config.Config, u *user.User) {
-	// Delete all user transactions first
-	db.Unscoped().Delete(&transaction.Transaction{}, "user_id = ?", u.ID)
+// BrokerageTransaction is a single row of a Schwab transaction export, in
+// either the CSV or the JSON format.
+type BrokerageTransaction struct {
+	Date        string `json:"Date"`
+	Action      string `json:"Action"`
+	Symbol      string `json:"Symbol"`
+	Description string `json:"Description"`
+	Quantity    string `json:"Quantity"`
+	Price       string `json:"Price"`
+	FeesComm    string `json:"Fees & Comm"`
+	Amount      string `json:"Amount"`
+}
 
-	files := loadTransactionFiles(cfg.Import.DownloadPath)
-	for _, f := range files {
-		filename := fmt.Sprintf("%s/%s", cfg.Import.DownloadPath, f.Name())
-		log.Println("Files to be read: ", filename)
-		csvFile, err := os.Open(filename)
-		if err != nil {
-			log.Println("Couldn't open the csv file", err)
-		}
+const FormatSchwabCSV = "schwab-csv"
 
-		// skip first line of file
-		var accountName string
-		// bio := bufio.NewReader(csvFile)
-		// acct, _, _ := bio.ReadLine()
+type schwabCSVImporter struct{}
 
-		// alias the account instead of what they provide
-		if strings.Contains(filename, "XXX953") {
-			accountName = "Brokerage"
-		}
-		if strings.Contains(filename, "XXX286") {
-			accountName = "IRA"
-		}
-		// _, err = csvFile.Seek(int64(len(acct)), io.SeekStart)
-		// if err != nil {
-		// 	log.Fatal(err)
-		// }
-		transactions := []transaction.Transaction{}
-		reader := csv.NewReader(csvFile)
-		// Header
-		header, err := reader.Read()
-		log.Println("headers", header)
-
-		for {
-			record, err := reader.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Println(err)
-				continue
-			}
+func init() {
+	Register(schwabCSVImporter{})
+}
 
-			tdate := record[csvTxDate]
-			if strings.Contains(tdate, "Transactions") {
+func (schwabCSVImporter) Format() string {
+	return FormatSchwabCSV
+}
+
+// Detect looks for the account title line or the column header.
+func (schwabCSVImporter) Detect(header []byte) bool {
+	h := strings.TrimPrefix(string(header), "\ufeff")
+	return strings.HasPrefix(h, `"Transactions  for account`) ||
+		strings.HasPrefix(h, `"Transactions for account`) ||
+		strings.HasPrefix(h, `"Date","Action","Symbol","Description","Quantity","Price","Fees & Comm","Amount"`)
+}
+
+func (schwabCSVImporter) Parse(r io.Reader) (*ParseResult, error) {
+	result := &ParseResult{}
+	reader := csv.NewReader(r)
+	// the account title line and the header have a different number of fields
+	reader.FieldsPerRecord = -1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				result.Errors = append(result.Errors, RowError{Line: parseErr.StartLine, Message: parseErr.Err.Error()})
 				continue
 			}
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
 
-			// write to DB
-			t := transaction.Transaction{
-				UserID:      u.ID,
-				Account:     accountName,
-				Date:        transactionDateFromDate(record[csvTxDate]),
-				Action:      record[csvAction],
-				Symbol:      record[csvSymbol],
-				Description: record[csvDescription],
-				Quantity:    safeStringToFloat(record[csvQuantity]),
-				Price:       safeStringToFloat(record[csvPrice]),
-				FeesComm:    safeStringToFloat(record[csvFees]),
-				Amount:      safeStringToFloat(record[csvAmount]),
+		// title, header and total lines
+		tdate := record[csvTxDate]
+		if strings.Contains(tdate, "Transactions") || tdate == "Date" {
+			// "Transactions  for account XXXX-1953 as of 02/04/2023 20:30:11 ET"
+			if strings.Contains(tdate, "account") && result.Account == "" {
+				result.Account = tdate
 			}
-
-			transactions = append(transactions, t)
+			continue
 		}
-
-		if err := transaction.CreateMany(db, transactions); err != nil {
-			log.Println(err)
+		if len(record) <= csvAmount {
+			result.Errors = append(result.Errors, RowError{
+				Line:    line,
+				Message: fmt.Sprintf("expected %d fields, got %d", csvAmount+1, len(record)),
+			})
+			continue
 		}
-		if e := os.Remove(filename); e != nil {
-			log.Println(err)
+
+		t, err := BrokerageTransaction{
+			Date:        record[csvTxDate],
+			Action:      record[csvAction],
+			Symbol:      record[csvSymbol],
+			Description: record[csvDescription],
+			Quantity:    record[csvQuantity],
+			Price:       record[csvPrice],
+			FeesComm:    record[csvFees],
+			Amount:      record[csvAmount],
+		}.toTransaction()
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{Line: line, Message: err.Error()})
+			continue
 		}
+		result.Transactions = append(result.Transactions, t)
+	}
+	return result, nil
+}
+
+func (bt BrokerageTransaction) toTransaction() (transaction.Transaction, error) {
+	date, err := transaction.ParseDate(transactionDateFromDate(bt.Date))
+	if err != nil {
+		return transaction.Transaction{}, fmt.Errorf("invalid date %q", bt.Date)
+	}
+	if bt.Action == "" {
+		return transaction.Transaction{}, fmt.Errorf("missing action")
+	}
+	quantity, err := safeStringToFloat(bt.Quantity)
+	if err != nil {
+		return transaction.Transaction{}, fmt.Errorf("invalid quantity %q", bt.Quantity)
+	}
+	price, err := safeStringToDecimal(bt.Price)
+	if err != nil {
+		return transaction.Transaction{}, fmt.Errorf("invalid price %q", bt.Price)
+	}
+	fees, err := safeStringToDecimal(bt.FeesComm)
+	if err != nil {
+		return transaction.Transaction{}, fmt.Errorf("invalid fees %q", bt.FeesComm)
+	}
+	amount, err := safeStringToDecimal(bt.Amount)
+	if err != nil {
+		return transaction.Transaction{}, fmt.Errorf("invalid amount %q", bt.Amount)
 	}
+	t := transaction.Transaction{
+		Date:        date,
+		Action:      bt.Action,
+		Symbol:      bt.Symbol,
+		Description: bt.Description,
+		Quantity:    quantity,
+		Price:       price,
+		FeesComm:    fees,
+		Amount:      amount,
+	}
+	if err := t.Classify(); err != nil {
+		return transaction.Transaction{}, err
+	}
+	return t, nil
 }
 
 func loadTransactionFiles(directory string) []os.FileInfo {
 	files := []os.FileInfo{}
 	filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			log.Fatalf(err.Error())
+			log.Println(err)
+			return err
 		}
 		if info.IsDir() {
 			return nil
@@ -119,17 +171,38 @@ func loadTransactionFiles(directory string) []os.FileInfo {
 	return files
 }
 
-func safeStringToFloat(str string) float64 {
-	if str == "" {
-		return 0.0
+func baseName(filename string) string {
+	return filepath.Base(filename)
+}
+
+func safeStringToFloat(str string) (float64, error) {
+	d, err := safeStringToDecimal(str)
+	if err != nil {
+		return 0.0, err
+	}
+	return d.InexactFloat64(), nil
+}
+
+// safeStringToDecimal reads amounts like -$1,234.56 exactly, and takes
+// accounting style ($1,234.56) as negative.
+func safeStringToDecimal(str string) (decimal.Decimal, error) {
+	s := strings.TrimSpace(str)
+	if s == "" {
+		return decimal.Zero, nil
+	}
+	negative := strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+	if negative {
+		s = strings.TrimSuffix(strings.TrimPrefix(s, "("), ")")
 	}
 	replacer := strings.NewReplacer("$", "", ",", "")
-	s := replacer.Replace(str)
-	c, err := strconv.ParseFloat(s, 64)
+	d, err := decimal.NewFromString(replacer.Replace(s))
 	if err != nil {
-		return 0.0
+		return decimal.Zero, err
+	}
+	if negative {
+		d = d.Neg()
 	}
-	return c
+	return d, nil
 }
 
 // 05/24/2021 as of 05/21/2021
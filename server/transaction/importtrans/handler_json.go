@@ -1,86 +1,130 @@
 package importtrans
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
-	"os"
-	"strings"
-
-	"github.com/wazupwiddat/postrack/server/config"
-	"github.com/wazupwiddat/postrack/server/transaction"
-	"github.com/wazupwiddat/postrack/server/user"
-	"gorm.io/gorm"
 )
 
 type JSONTransactions struct {
-	FromDate                string `json:"FromDate"`
-	ToDate                  string `json:"ToDate"`
-	TotalTransactionsAmount string `json:"TotalTransactionsAmount"`
-	BrokerageTransactions   []struct {
-		Date        string `json:"Date"`
-		Action      string `json:"Action"`
-		Symbol      string `json:"Symbol"`
-		Description string `json:"Description"`
-		Quantity    string `json:"Quantity"`
-		Price       string `json:"Price"`
-		FeesComm    string `json:"Fees & Comm"`
-		Amount      string `json:"Amount"`
-	} `json:"BrokerageTransactions"`
+	FromDate                string                 `json:"FromDate"`
+	ToDate                  string                 `json:"ToDate"`
+	TotalTransactionsAmount string                 `json:"TotalTransactionsAmount"`
+	BrokerageTransactions   []BrokerageTransaction `json:"BrokerageTransactions"`
 }
 
-func ImportUploadedJSONFiles(db *gorm.DB, cfg *config.Config, u *user.User) {
-	// Delete all user transactions first
-	db.Unscoped().Delete(&transaction.Transaction{}, "user_id = ?", u.ID)
+const FormatSchwabJSON = "schwab-json"
 
-	files := loadTransactionFiles(cfg.Import.DownloadPath)
-	for _, f := range files {
-		filename := fmt.Sprintf("%s/%s", cfg.Import.DownloadPath, f.Name())
-		log.Println("JSON Files to be read: ", filename)
+type schwabJSONImporter struct{}
 
-		jsonFile, err := os.Open(filename)
-		if err != nil {
-			log.Println("Couldn't open the json file", err)
-		}
+func init() {
+	Register(schwabJSONImporter{})
+}
 
-		byteValue, _ := io.ReadAll(jsonFile)
-		var jsonTransactions JSONTransactions
-		json.Unmarshal(byteValue, &jsonTransactions)
+func (schwabJSONImporter) Format() string {
+	return FormatSchwabJSON
+}
+
+// Detect looks for the BrokerageTransactions list of the export, which
+// follows a few short date and total fields.
+func (schwabJSONImporter) Detect(header []byte) bool {
+	h := bytes.TrimLeft(bytes.TrimPrefix(header, []byte("\ufeff")), " \t\r\n")
+	return bytes.HasPrefix(h, []byte("{")) && bytes.Contains(h, []byte(`"BrokerageTransactions"`))
+}
 
-		var accountName string
+func (schwabJSONImporter) Parse(r io.Reader) (*ParseResult, error) {
+	byteValue, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
 
-		// alias the account instead of what they provide
-		if strings.Contains(filename, "XXX953") {
-			accountName = "Brokerage"
+	result := &ParseResult{}
+	found := false
+	// walk the document by hand so every row can be reported by line
+	dec := json.NewDecoder(bytes.NewReader(byteValue))
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, jsonError(byteValue, dec, err)
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, jsonError(byteValue, dec, err)
 		}
-		if strings.Contains(filename, "XXX286") {
-			accountName = "IRA"
+		if key != "BrokerageTransactions" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil, jsonError(byteValue, dec, err)
+			}
+			continue
 		}
 
-		transactions := []transaction.Transaction{}
-		for _, jt := range jsonTransactions.BrokerageTransactions {
-			// create DB record add to transaction list
-			t := transaction.Transaction{
-				UserID:      u.ID,
-				Account:     accountName,
-				Date:        transactionDateFromDate(jt.Date),
-				Action:      jt.Action,
-				Symbol:      jt.Symbol,
-				Description: jt.Description,
-				Quantity:    safeStringToFloat(jt.Quantity),
-				Price:       safeStringToFloat(jt.Price),
-				FeesComm:    safeStringToFloat(jt.FeesComm),
-				Amount:      safeStringToFloat(jt.Amount),
+		found = true
+		if err := expectDelim(dec, '['); err != nil {
+			return nil, jsonError(byteValue, dec, err)
+		}
+		for dec.More() {
+			line := lineAt(byteValue, dec.InputOffset())
+			var jt BrokerageTransaction
+			if err := dec.Decode(&jt); err != nil {
+				var typeErr *json.UnmarshalTypeError
+				if errors.As(err, &typeErr) {
+					result.Errors = append(result.Errors, RowError{Line: line, Message: err.Error()})
+					continue
+				}
+				return nil, jsonError(byteValue, dec, err)
 			}
 
-			transactions = append(transactions, t)
+			// create DB record add to transaction list
+			t, err := jt.toTransaction()
+			if err != nil {
+				result.Errors = append(result.Errors, RowError{Line: line, Message: err.Error()})
+				continue
+			}
+			result.Transactions = append(result.Transactions, t)
 		}
-		if err := transaction.CreateMany(db, transactions); err != nil {
-			log.Println(err)
+		if err := expectDelim(dec, ']'); err != nil {
+			return nil, jsonError(byteValue, dec, err)
 		}
-		if e := os.Remove(filename); e != nil {
-			log.Println(err)
+	}
+	if !found {
+		return nil, fmt.Errorf("no BrokerageTransactions found")
+	}
+	return result, nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected %q, got %v", delim, tok)
+	}
+	return nil
+}
+
+func jsonError(data []byte, dec *json.Decoder, err error) error {
+	offset := dec.InputOffset()
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	}
+	return fmt.Errorf("malformed JSON at line %d: %w", lineAt(data, offset), err)
+}
+
+// lineAt returns the line of the next value at or after offset.
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	for offset < int64(len(data)) {
+		c := data[offset]
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' && c != ',' {
+			break
 		}
+		offset++
 	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
 }
@@ -0,0 +1,340 @@
+package importtrans
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/transaction"
+)
+
+const FormatIBKRFlexXML = "ibkr-flex-xml"
+
+type ibkrImporter struct{}
+
+func init() {
+	Register(ibkrImporter{})
+}
+
+func (ibkrImporter) Format() string {
+	return FormatIBKRFlexXML
+}
+
+func (ibkrImporter) Detect(header []byte) bool {
+	return bytes.Contains(header, []byte("<FlexQueryResponse")) ||
+		bytes.Contains(header, []byte("<FlexStatements"))
+}
+
+// Parse reads the Trades, OptionEAE and CorporateActions sections of a Flex
+// Query statement.  The option legs of assignments, exercises and
+// expirations show up in Trades as BookTrades too; those are taken from
+// OptionEAE instead, and the stock legs from Trades.
+func (ibkrImporter) Parse(r io.Reader) (*ParseResult, error) {
+	result := &ParseResult{}
+	dec := xml.NewDecoder(r)
+	found := false
+	// the account of the statement being read; a Flex query can cover
+	// several accounts, one statement each
+	account := ""
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line, _ := dec.InputPos()
+			return nil, fmt.Errorf("malformed XML at line %d: %w", line, err)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		line, _ := dec.InputPos()
+		attrs := ibkrAttrs(se)
+
+		var t *transaction.Transaction
+		switch se.Name.Local {
+		case "FlexQueryResponse", "FlexStatements":
+			found = true
+			continue
+		case "FlexStatement":
+			found = true
+			account = attrs["accountId"]
+			if result.Account == "" {
+				result.Account = account
+			}
+			continue
+		case "Trade":
+			t, err = ibkrTrade(attrs)
+		case "OptionEAE":
+			// the section and its rows share a name
+			if len(se.Attr) == 0 {
+				continue
+			}
+			t, err = ibkrOptionEAE(attrs)
+		case "CorporateAction":
+			t, err = ibkrCorporateAction(attrs)
+		default:
+			continue
+		}
+		if err == nil && t != nil {
+			err = t.Classify()
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{Line: line, Message: err.Error()})
+			continue
+		}
+		if t != nil {
+			t.Account = firstNonEmpty(attrs["accountId"], account)
+			result.Transactions = append(result.Transactions, *t)
+		}
+	}
+	if !found {
+		return nil, errors.New("no FlexQueryResponse found")
+	}
+	return result, nil
+}
+
+func ibkrAttrs(se xml.StartElement) map[string]string {
+	attrs := map[string]string{}
+	for _, a := range se.Attr {
+		attrs[a.Name.Local] = strings.TrimSpace(a.Value)
+	}
+	return attrs
+}
+
+func ibkrTrade(attrs map[string]string) (*transaction.Transaction, error) {
+	category := attrs["assetCategory"]
+	if category == "OPT" && attrs["transactionType"] == "BookTrade" {
+		return nil, nil
+	}
+
+	date, err := ibkrDate(firstNonEmpty(attrs["tradeDate"], attrs["dateTime"]))
+	if err != nil {
+		return nil, err
+	}
+	numbers, err := ibkrNumbers(attrs, "quantity", "tradePrice", "proceeds", "ibCommission", "taxes", "netCash", "multiplier")
+	if err != nil {
+		return nil, err
+	}
+
+	buySell := strings.ToUpper(attrs["buySell"])
+	if buySell == "" {
+		buySell = "BUY"
+		if numbers["quantity"].IsNegative() {
+			buySell = "SELL"
+		}
+	}
+
+	var action, symbol string
+	var option *transaction.Option
+	switch category {
+	case "STK", "ETF", "FUND":
+		action = "Buy"
+		if strings.HasPrefix(buySell, "SELL") {
+			action = "Sell"
+		}
+		symbol = attrs["symbol"]
+	case "OPT":
+		o, err := ibkrOption(attrs)
+		if err != nil {
+			return nil, err
+		}
+		symbol = o.Symbol()
+		option = &o
+		// "O", "C" or "C;O" when a trade both closes and opens
+		openClose := "Open"
+		if strings.HasPrefix(attrs["openCloseIndicator"], "C") {
+			openClose = "Close"
+		}
+		action = "Buy to " + openClose
+		if strings.HasPrefix(buySell, "SELL") {
+			action = "Sell to " + openClose
+		}
+	default:
+		return nil, fmt.Errorf("unsupported asset category %q", category)
+	}
+
+	multiplier := numbers["multiplier"]
+	if multiplier.IsZero() {
+		multiplier = decimal.NewFromInt(1)
+	}
+	fees := numbers["ibCommission"].Add(numbers["taxes"])
+	amount := numbers["netCash"]
+	if attrs["netCash"] == "" {
+		proceeds := numbers["proceeds"]
+		if attrs["proceeds"] == "" {
+			proceeds = numbers["quantity"].Neg().Mul(numbers["tradePrice"]).Mul(multiplier)
+		}
+		amount = proceeds.Add(fees)
+	}
+
+	return &transaction.Transaction{
+		Date:        date,
+		Action:      action,
+		Symbol:      symbol,
+		Description: truncate(attrs["description"], 250),
+		Quantity:    numbers["quantity"].Abs().InexactFloat64(),
+		Price:       numbers["tradePrice"],
+		FeesComm:    fees.Abs(),
+		Amount:      amount.Round(2),
+		Option:      option,
+	}, nil
+}
+
+func ibkrOptionEAE(attrs map[string]string) (*transaction.Transaction, error) {
+	// the stock legs are in Trades
+	if attrs["assetCategory"] != "OPT" {
+		return nil, nil
+	}
+
+	var action string
+	switch attrs["transactionType"] {
+	case "Assignment":
+		action = "Assigned"
+	case "Expiration":
+		action = "Expired"
+	case "Exercise":
+		action = "Exchange or Exercise"
+	default:
+		return nil, nil
+	}
+
+	date, err := ibkrDate(firstNonEmpty(attrs["date"], attrs["tradeDate"]))
+	if err != nil {
+		return nil, err
+	}
+	o, err := ibkrOption(attrs)
+	if err != nil {
+		return nil, err
+	}
+	numbers, err := ibkrNumbers(attrs, "quantity")
+	if err != nil {
+		return nil, err
+	}
+	return &transaction.Transaction{
+		Date:        date,
+		Action:      action,
+		Symbol:      o.Symbol(),
+		Description: truncate(attrs["description"], 250),
+		Quantity:    numbers["quantity"].Abs().InexactFloat64(),
+		Option:      &o,
+	}, nil
+}
+
+func ibkrCorporateAction(attrs map[string]string) (*transaction.Transaction, error) {
+	date, err := ibkrDate(firstNonEmpty(attrs["reportDate"], attrs["dateTime"]))
+	if err != nil {
+		return nil, err
+	}
+	numbers, err := ibkrNumbers(attrs, "quantity", "amount", "proceeds")
+	if err != nil {
+		return nil, err
+	}
+
+	// the shares a corporate action adds or removes; a reverse split takes
+	// the old shares out in a row of their own, which keeps its sign
+	quantity := numbers["quantity"].Abs()
+	action := "Corporate Action"
+	switch attrs["type"] {
+	case "FS":
+		action = "Stock Split"
+	case "RS":
+		action = "Reverse Split"
+		quantity = numbers["quantity"]
+	case "SO":
+		action = "Spin-off"
+	case "TC":
+		action = "Ticker Change"
+	case "TO", "TM":
+		action = "Merger"
+	}
+	return &transaction.Transaction{
+		Date:        date,
+		Action:      action,
+		Symbol:      attrs["symbol"],
+		Description: truncate(attrs["description"], 250),
+		Quantity:    quantity.InexactFloat64(),
+		Amount:      numbers["amount"].Add(numbers["proceeds"]),
+	}, nil
+}
+
+// ibkrOption reads the contract of an option from IBKR's OCC symbol, e.g.
+// "AAPL  240119C00190000", or its description, e.g. "AAPL 19JAN24 190 C",
+// falling back on the contract's own attributes.
+func ibkrOption(attrs map[string]string) (transaction.Option, error) {
+	numbers, err := ibkrNumbers(attrs, "strike", "multiplier")
+	if err != nil {
+		return transaction.Option{}, err
+	}
+	o, ok := transaction.ParseOption(attrs["symbol"])
+	if !ok {
+		o, ok = transaction.ParseOption(attrs["description"])
+	}
+	if !ok {
+		expiry, err := ibkrDate(attrs["expiry"])
+		if err != nil {
+			return transaction.Option{}, fmt.Errorf("invalid option symbol %q", attrs["symbol"])
+		}
+		if attrs["underlyingSymbol"] == "" || (attrs["putCall"] != "C" && attrs["putCall"] != "P") {
+			return transaction.Option{}, fmt.Errorf("invalid option symbol %q", attrs["symbol"])
+		}
+		o = transaction.Option{
+			Root:       attrs["underlyingSymbol"],
+			Underlying: attrs["underlyingSymbol"],
+			Expiry:     expiry,
+			Strike:     numbers["strike"],
+			PutCall:    attrs["putCall"],
+			Multiplier: transaction.StandardMultiplier,
+		}
+	}
+	if attrs["underlyingSymbol"] != "" {
+		o.Underlying = attrs["underlyingSymbol"]
+	}
+	if numbers["multiplier"].IsPositive() {
+		o.Multiplier = numbers["multiplier"].InexactFloat64()
+	}
+	return o, nil
+}
+
+var ibkrDateLayouts = []string{"20060102", "2006-01-02", "01/02/2006", "01/02/06"}
+
+// ibkrDate accepts the date formats a Flex Query can be set up with, with or
+// without a time after ';' or ','.
+func ibkrDate(value string) (time.Time, error) {
+	d := strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == ',' || r == ' ' })
+	if len(d) > 0 {
+		for _, layout := range ibkrDateLayouts {
+			if t, err := time.Parse(layout, d[0]); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+func ibkrNumbers(attrs map[string]string, names ...string) (map[string]decimal.Decimal, error) {
+	numbers := map[string]decimal.Decimal{}
+	for _, name := range names {
+		d, err := safeStringToDecimal(attrs[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", name, attrs[name])
+		}
+		numbers[name] = d
+	}
+	return numbers, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
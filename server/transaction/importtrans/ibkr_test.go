@@ -0,0 +1,92 @@
+package importtrans_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/wazupwiddat/postrack/server/split"
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/transaction/importtrans"
+)
+
+func TestParseIBKRFlexXML(t *testing.T) {
+	f, err := os.Open("../../../test_data/ibkrFlexQuery.xml")
+	if err != nil {
+		t.Fatalf("Unable to open file %s", "./test_data/ibkrFlexQuery.xml")
+	}
+	defer f.Close()
+
+	imp, r, err := importtrans.DetectReader("ibkrFlexQuery.xml", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imp.Format() != importtrans.FormatIBKRFlexXML {
+		t.Fatalf("Expected IBKR importer, got %s", imp.Format())
+	}
+	result, err := imp.Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Account != "U1234567" {
+		t.Errorf("Expected account U1234567, got %q", result.Account)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Line != 10 {
+		t.Errorf("Expected an error on line 10, got %v", result.Errors)
+	}
+
+	expected := []transaction.Transaction{
+		{Date: date("01/02/2024"), Action: "Sell to Open", Symbol: "AAPL 01/19/2024 190.00 C", Quantity: 1, Price: dec("1.5"), FeesComm: dec("0.65"), Amount: dec("149.35")},
+		{Date: date("01/03/2024"), Action: "Sell to Open", Symbol: "XSP 01/19/2024 470.00 P", Quantity: 2, Price: dec("0.8"), FeesComm: dec("1.3"), Amount: dec("158.7")},
+		{Date: date("01/10/2024"), Action: "Buy to Close", Symbol: "XSP 01/19/2024 470.00 P", Quantity: 2, Price: dec("0.2"), FeesComm: dec("1.3"), Amount: dec("-41.3")},
+		{Date: date("01/19/2024"), Action: "Sell", Symbol: "AAPL", Quantity: 100, Price: dec("190"), FeesComm: dec("0.03"), Amount: dec("18999.97")},
+		{Date: date("01/10/2024"), Action: "Buy", Symbol: "XYZ", Quantity: 1000, Price: dec("2"), FeesComm: dec("1"), Amount: dec("-2001")},
+		{Date: date("01/19/2024"), Action: "Assigned", Symbol: "AAPL 01/19/2024 190.00 C", Quantity: 1},
+		{Date: date("06/10/2024"), Action: "Stock Split", Symbol: "NVDA", Quantity: 90},
+		{Date: date("03/15/2024"), Action: "Reverse Split", Symbol: "XYZ", Quantity: -1000},
+		{Date: date("03/15/2024"), Action: "Reverse Split", Symbol: "XYZ", Quantity: 100},
+		{Date: date("01/05/2024"), Action: "Buy", Symbol: "MSFT", Quantity: 10, Price: dec("370"), FeesComm: dec("1"), Amount: dec("-3701")},
+	}
+	if len(result.Transactions) != len(expected) {
+		t.Fatalf("Expected %d transactions, got %d", len(expected), len(result.Transactions))
+	}
+	for i, tran := range result.Transactions {
+		e := expected[i]
+		account := "U1234567"
+		if e.Symbol == "MSFT" {
+			account = "U7654321"
+		}
+		if tran.Account != account {
+			t.Errorf("Expected %v in account %s, got %q", e, account, tran.Account)
+		}
+		if tran.Date != e.Date || tran.Action != e.Action || tran.Symbol != e.Symbol || tran.Quantity != e.Quantity ||
+			!tran.Price.Equal(e.Price) || !tran.FeesComm.Equal(e.FeesComm) || !tran.Amount.Equal(e.Amount) {
+			t.Errorf("Expected %v but got %v", e, tran)
+		}
+	}
+
+	// a 1 for 10 reverse split, not a forward one
+	proposals := split.Detect(result.Transactions, nil)
+	for _, p := range proposals {
+		if p.Symbol == "XYZ" && (p.Numerator != 1 || p.Denominator != 10) {
+			t.Errorf("Expected XYZ to split 1 for 10, got %d/%d", p.Numerator, p.Denominator)
+		}
+	}
+	if len(proposals) != 2 {
+		t.Errorf("Expected splits of NVDA and XYZ, got %v", proposals)
+	}
+
+	trans := transaction.Transactions(result.Transactions)
+	positions := trans.MergeTransactions(nil, nil).CollectPositions()
+	for _, pos := range positions {
+		switch pos.Symbol {
+		case "AAPL 01/19/2024 190.00 C":
+			if pos.Disposition != transaction.Disposition(3) {
+				t.Errorf("Expected AAPL call to be assigned")
+			}
+		case "XSP 01/19/2024 470.00 P":
+			if pos.Disposition != transaction.Disposition(1) || pos.Quantity != 0 {
+				t.Errorf("Expected XSP put to be closed")
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package importtrans
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+)
+
+// Importer reads the transaction export of one broker.  Importers register
+// themselves from init, and every uploaded file is routed to the first
+// importer that recognizes it.
+type Importer interface {
+	// Format names the export format, e.g. "schwab-csv".
+	Format() string
+	// Detect reports whether a file starting with header is in this format.
+	Detect(header []byte) bool
+	// Parse reads a single file.  An error means the file as a whole could
+	// not be read; rows that can't be imported are reported in the result.
+	Parse(r io.Reader) (*ParseResult, error)
+}
+
+// RowError describes a row of an uploaded file that could not be imported.
+type RowError struct {
+	Line    int
+	Message string
+}
+
+// ParseResult holds the broker rows read out of a single uploaded file.
+type ParseResult struct {
+	// Account is the account line of the export, if it has one, and is
+	// used together with the file name to find the account alias.  Exports
+	// that cover several accounts set the account of each transaction
+	// instead, and Account names the account of the rows without one.
+	Account      string
+	Transactions []transaction.Transaction
+	// Errors are the rows that could not be imported.
+	Errors []RowError
+}
+
+// HeaderSize is how much of a file Detect gets to see.
+const HeaderSize = 512
+
+type UnknownFormatError struct {
+	Name string
+}
+
+func (e *UnknownFormatError) Error() string {
+	return fmt.Sprintf("'%s' is not in a supported import format", e.Name)
+}
+
+type EmptyFileError struct {
+	Name string
+}
+
+func (e *EmptyFileError) Error() string {
+	return fmt.Sprintf("'%s' is empty", e.Name)
+}
+
+var importers []Importer
+
+func Register(imp Importer) {
+	importers = append(importers, imp)
+}
+
+// Detect returns the importer for a file starting with header, or nil.
+func Detect(header []byte) Importer {
+	for _, imp := range importers {
+		if imp.Detect(header) {
+			return imp
+		}
+	}
+	return nil
+}
+
+// DetectReader sniffs the start of r and returns its importer together with
+// a reader that still yields the whole file.
+func DetectReader(name string, r io.Reader) (Importer, io.Reader, error) {
+	br := bufio.NewReaderSize(r, HeaderSize)
+	header, err := br.Peek(HeaderSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+	imp := Detect(header)
+	if imp == nil {
+		return nil, nil, &UnknownFormatError{Name: name}
+	}
+	return imp, br, nil
+}
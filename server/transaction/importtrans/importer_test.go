@@ -0,0 +1,144 @@
+package importtrans_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/transaction/importtrans"
+)
+
+const schwabJSON = `{
+  "FromDate": "01/01/2023",
+  "ToDate": "01/31/2023",
+  "TotalTransactionsAmount": "-$10.00",
+  "BrokerageTransactions": [
+    {
+      "Date": "01/03/2023 as of 01/02/2023",
+      "Action": "Buy",
+      "Symbol": "AAPL",
+      "Description": "APPLE INC",
+      "Quantity": "10",
+      "Price": "$1.00",
+      "Fees & Comm": "",
+      "Amount": "-$10.00"
+    },
+    {
+      "Date": "not a date",
+      "Action": "Buy"
+    }
+  ]
+}`
+
+const schwabCSV = `"Transactions  for account XXXX-1953 as of 02/04/2023 20:30:11 ET"
+"Date","Action","Symbol","Description","Quantity","Price","Fees & Comm","Amount",
+"01/03/2023","Sell to Open","AAPL 01/20/2023 150.00 C","CALL APPLE INC","1","$1.50","$0.66","$149.34",
+"01/04/2023","Buy","AAPL","APPLE INC","x","$1.00","","-$10.00",
+"01/05/2023","Buy","MSFT","MICROSOFT CORP","5","$246.91","","($1,234.55)",
+"Transactions Total","","","","","","","($1,095.21)",
+`
+
+func TestDetect(t *testing.T) {
+	cases := map[string]string{
+		schwabJSON:              importtrans.FormatSchwabJSON,
+		schwabCSV:               importtrans.FormatSchwabCSV,
+		"\ufeff" + schwabCSV:    importtrans.FormatSchwabCSV,
+		`{"something": "else"}`: "",
+		"hello world":           "",
+	}
+	for doc, expected := range cases {
+		imp := importtrans.Detect([]byte(doc))
+		if expected == "" {
+			if imp != nil {
+				t.Errorf("Expected no importer, got %s", imp.Format())
+			}
+			continue
+		}
+		if imp == nil || imp.Format() != expected {
+			t.Errorf("Expected importer %s, got %v", expected, imp)
+		}
+	}
+}
+
+func TestParseSchwabJSON(t *testing.T) {
+	imp, r, err := importtrans.DetectReader("XXX953.json", strings.NewReader(schwabJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := imp.Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Transactions) != 1 {
+		t.Fatalf("Expected 1 transaction, got %d", len(result.Transactions))
+	}
+	tran := result.Transactions[0]
+	if !tran.Date.Equal(date("01/02/2023")) || tran.Symbol != "AAPL" || tran.Quantity != 10 || !tran.Amount.Equal(dec("-10")) {
+		t.Errorf("Unexpected transaction %v", tran)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Line != 16 {
+		t.Errorf("Expected an error on line 16, got %v", result.Errors)
+	}
+}
+
+func TestParseSchwabJSONMalformed(t *testing.T) {
+	imp := importtrans.Detect([]byte(schwabJSON))
+	_, err := imp.Parse(strings.NewReader(schwabJSON[:200]))
+	if err == nil {
+		t.Errorf("Expected malformed JSON to fail")
+	}
+}
+
+func TestParseSchwabCSV(t *testing.T) {
+	imp := importtrans.Detect([]byte(schwabCSV))
+	result, err := imp.Parse(strings.NewReader(schwabCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result.Account, "XXXX-1953") {
+		t.Errorf("Expected the account line, got %q", result.Account)
+	}
+	if len(result.Transactions) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(result.Transactions))
+	}
+	tran := result.Transactions[0]
+	if tran.Action != "Sell to Open" || !tran.FeesComm.Equal(dec("0.66")) || !tran.Amount.Equal(dec("149.34")) {
+		t.Errorf("Unexpected transaction %v", tran)
+	}
+	// brokers write negative amounts in parentheses
+	if tran := result.Transactions[1]; !tran.Amount.Equal(dec("-1234.55")) || !tran.Price.Equal(dec("246.91")) {
+		t.Errorf("Expected an amount of -1234.55, got %s", tran.Amount)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Line != 4 {
+		t.Errorf("Expected an error on line 4, got %v", result.Errors)
+	}
+}
+
+func TestParseUnknownAction(t *testing.T) {
+	csv := `"Date","Action","Symbol","Description","Quantity","Price","Fees & Comm","Amount",
+"01/03/2023","Buy","AAPL","APPLE INC","10","$1.00","","-$10.00",
+"01/04/2023","Mystery","AAPL","APPLE INC","10","$1.00","","-$10.00",
+`
+	imp := importtrans.Detect([]byte(csv))
+	result, err := imp.Parse(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Transactions) != 1 || result.Transactions[0].Type != transaction.ActionBuy {
+		t.Errorf("Expected one typed transaction, got %v", result.Transactions)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Line != 3 {
+		t.Errorf("Expected the unknown action reported on line 3, got %v", result.Errors)
+	}
+}
+
+func date(value string) time.Time {
+	d, _ := time.Parse("01/02/2006", value)
+	return d
+}
+
+func dec(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
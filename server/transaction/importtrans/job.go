@@ -0,0 +1,169 @@
+package importtrans
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/config"
+	"github.com/wazupwiddat/postrack/server/importjob"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+// ImportUploadedFiles imports every file uploaded for the job, routing each
+// one to the importer for its format, and records the outcome on the job.
+func ImportUploadedFiles(db *gorm.DB, cfg *config.Config, u *user.User, job *importjob.ImportJob) *MergeResult {
+	result := &MergeResult{}
+
+	job.State = importjob.StateRunning
+	if _, err := importjob.Update(db, job); err != nil {
+		log.Println(err)
+	}
+
+	dir := UploadDir(cfg, job)
+	defer func() {
+		if err := RemoveUploads(cfg, job); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	aliases, err := accountalias.FindAllByUser(db, u.ID)
+	if err != nil {
+		log.Println(err)
+		failJob(db, job, err)
+		return result
+	}
+
+	files := loadTransactionFiles(dir)
+	batch, err := startBatch(db, u, files)
+	if err != nil {
+		log.Println(err)
+		failJob(db, job, err)
+		return result
+	}
+	defer finishBatch(db, batch, result)
+	job.ImportBatchID = batch.ID
+
+	formats := []string{}
+	failedFiles := 0
+	for _, f := range files {
+		filename := filepath.Join(dir, f.Name())
+		log.Println("Files to be read: ", filename)
+
+		jobFile, err := importFile(db, u, job, batch.ID, aliases, filename)
+		if err != nil {
+			log.Println(err)
+			jobFile.Error = err.Error()
+			failedFiles++
+		} else {
+			log.Printf("%s: inserted %d, skipped %d, conflicting %d, invalid %d\n",
+				f.Name(), jobFile.Inserted, jobFile.Skipped, jobFile.Conflicting, jobFile.Invalid)
+			result.Inserted += jobFile.Inserted
+			result.Skipped += jobFile.Skipped
+			result.Conflicting += jobFile.Conflicting
+		}
+		if jobFile.Format != "" && !contains(formats, jobFile.Format) {
+			formats = append(formats, jobFile.Format)
+		}
+		if _, err := importjob.CreateFile(db, jobFile); err != nil {
+			log.Println(err)
+		}
+	}
+	batch.Format = strings.Join(formats, ",")
+	proposeSplits(db, u)
+
+	if failedFiles > 0 {
+		failJob(db, job, fmt.Errorf("%d of %d files could not be imported", failedFiles, len(files)))
+		return result
+	}
+	job.State = importjob.StateDone
+	if _, err := importjob.Update(db, job); err != nil {
+		log.Println(err)
+	}
+	return result
+}
+
+func importFile(db *gorm.DB, u *user.User, job *importjob.ImportJob, batchID uint,
+	aliases accountalias.Aliases, filename string) (*importjob.ImportJobFile, error) {
+	jobFile := &importjob.ImportJobFile{
+		ImportJobID: job.ID,
+		Name:        baseName(filename),
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return jobFile, err
+	}
+	defer file.Close()
+
+	imp, r, err := DetectReader(jobFile.Name, file)
+	if err != nil {
+		return jobFile, err
+	}
+	jobFile.Format = imp.Format()
+
+	parsed, err := imp.Parse(r)
+	if err != nil {
+		return jobFile, err
+	}
+	jobFile.Rows = len(parsed.Transactions) + len(parsed.Errors)
+	jobFile.Invalid = len(parsed.Errors)
+	recordRowErrors(db, job, jobFile.Name, parsed.Errors)
+
+	// alias the account instead of what they provide
+	accountName := aliases.Resolve(jobFile.Name, parsed.Account)
+	transactions := parsed.Transactions
+	for idx := range transactions {
+		transactions[idx].UserID = u.ID
+		transactions[idx].ImportBatchID = batchID
+		if transactions[idx].Account == "" {
+			transactions[idx].Account = accountName
+		} else {
+			transactions[idx].Account = aliases.ResolveAccount(transactions[idx].Account)
+		}
+	}
+	merged, err := Merge(db, u, transactions)
+	if err != nil {
+		return jobFile, err
+	}
+	jobFile.Inserted = merged.Inserted
+	jobFile.Skipped = merged.Skipped
+	jobFile.Conflicting = merged.Conflicting
+	return jobFile, nil
+}
+
+func recordRowErrors(db *gorm.DB, job *importjob.ImportJob, file string, rowErrors []RowError) {
+	errs := []importjob.ImportRowError{}
+	for _, re := range rowErrors {
+		errs = append(errs, importjob.ImportRowError{
+			ImportJobID: job.ID,
+			File:        file,
+			Line:        re.Line,
+			Message:     re.Message,
+		})
+	}
+	if err := importjob.CreateRowErrors(db, errs); err != nil {
+		log.Println(err)
+	}
+}
+
+func failJob(db *gorm.DB, job *importjob.ImportJob, err error) {
+	job.State = importjob.StateFailed
+	job.Error = err.Error()
+	if _, err := importjob.Update(db, job); err != nil {
+		log.Println(err)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package importtrans
+
+import (
+	"fmt"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type MergeResult struct {
+	Inserted    int
+	Skipped     int
+	Conflicting int
+	Conflicts   []transaction.Transaction
+}
+
+// MergePlan splits incoming broker rows into the ones that are new, the ones
+// already stored and the ones that conflict with a stored row.
+type MergePlan struct {
+	New        []transaction.Transaction
+	Duplicates []transaction.Transaction
+	Conflicts  []transaction.Transaction
+}
+
+// Merge stores the incoming broker rows that are not already stored for the
+// user.  A row is skipped when its fingerprint is already stored, and is a
+// conflict when the broker reports different numbers for a row we already
+// have (same account, date, action and symbol in the same position).
+func Merge(db *gorm.DB, u *user.User, incoming []transaction.Transaction) (*MergeResult, error) {
+	if len(incoming) == 0 {
+		return &MergeResult{}, nil
+	}
+	stored, err := findStored(db, u, incoming)
+	if err != nil {
+		return nil, err
+	}
+	plan := PlanMerge(stored, incoming)
+
+	newTransactions := []transaction.Transaction{}
+	for _, tran := range plan.New {
+		tran.UserID = u.ID
+		newTransactions = append(newTransactions, tran)
+	}
+	if len(newTransactions) > 0 {
+		if err := transaction.CreateMany(db, newTransactions); err != nil {
+			return nil, err
+		}
+	}
+	return &MergeResult{
+		Inserted:    len(newTransactions),
+		Skipped:     len(plan.Duplicates),
+		Conflicting: len(plan.Conflicts),
+		Conflicts:   plan.Conflicts,
+	}, nil
+}
+
+// PlanMerge works out what Merge would do with the incoming rows given the
+// stored ones, without touching the database.
+func PlanMerge(stored []transaction.Transaction, incoming []transaction.Transaction) *MergePlan {
+	st := transaction.Transactions(stored)
+	st = *st.WithFingerprints()
+	storedFingerprints := map[string]bool{}
+	storedSlots := map[string]string{}
+	for idx, slot := range slotKeys(st) {
+		storedFingerprints[st[idx].Fingerprint] = true
+		storedSlots[slot] = st[idx].Fingerprint
+	}
+
+	in := transaction.Transactions(incoming)
+	in = *in.WithFingerprints()
+	incomingSlots := slotKeys(in)
+	incomingFingerprints := map[string]bool{}
+	for _, tran := range in {
+		incomingFingerprints[tran.Fingerprint] = true
+	}
+
+	plan := &MergePlan{}
+	for idx, tran := range in {
+		if storedFingerprints[tran.Fingerprint] {
+			plan.Duplicates = append(plan.Duplicates, tran)
+			continue
+		}
+		// the stored row in this slot is not part of the upload, so the
+		// broker must have changed it
+		if fp, ok := storedSlots[incomingSlots[idx]]; ok && !incomingFingerprints[fp] {
+			plan.Conflicts = append(plan.Conflicts, tran)
+			continue
+		}
+		plan.New = append(plan.New, tran)
+	}
+	return plan
+}
+
+// findStored returns the stored rows incoming is merged against.  Rows only
+// match stored rows of the same date, so only the dates incoming spans are
+// loaded.
+func findStored(db *gorm.DB, u *user.User, incoming []transaction.Transaction) ([]transaction.Transaction, error) {
+	if len(incoming) == 0 {
+		return nil, nil
+	}
+	start, end := incoming[0].Date, incoming[0].Date
+	for _, tran := range incoming {
+		if tran.Date.Before(start) {
+			start = tran.Date
+		}
+		if tran.Date.After(end) {
+			end = tran.Date
+		}
+	}
+	return transaction.FindAllByUserBetween(db, u, start, end)
+}
+
+// slotKeys keys each row by account, date, action, symbol and its position
+// among the rows sharing those values.
+func slotKeys(trans transaction.Transactions) []string {
+	occurrences := map[string]int{}
+	keys := []string{}
+	for _, tran := range trans {
+		key := fmt.Sprintf("%s|%s|%s|%s", tran.Account, tran.Date.Format(transaction.DateLayout), tran.Action, tran.Symbol)
+		keys = append(keys, fmt.Sprintf("%s|%d", key, occurrences[key]))
+		occurrences[key]++
+	}
+	return keys
+}
@@ -0,0 +1,47 @@
+package importtrans_test
+
+import (
+	"testing"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/transaction/importtrans"
+)
+
+func TestPlanMerge(t *testing.T) {
+	buy := transaction.Transaction{Account: "...678", Date: date("01/02/2024"), Action: "Buy", Symbol: "AAPL", Quantity: 10, Price: dec("185"), Amount: dec("-1850")}
+	sell := transaction.Transaction{Account: "...678", Date: date("01/03/2024"), Action: "Sell", Symbol: "AAPL", Quantity: 10, Price: dec("190"), Amount: dec("1900")}
+	later := transaction.Transaction{Account: "...678", Date: date("01/04/2024"), Action: "Buy", Symbol: "MSFT", Quantity: 5, Price: dec("370"), Amount: dec("-1850")}
+	changed := sell
+	changed.Amount = dec("1899.35")
+
+	tests := []struct {
+		name       string
+		stored     []transaction.Transaction
+		incoming   []transaction.Transaction
+		new        int
+		duplicates int
+		conflicts  int
+	}{
+		{"first upload", nil, []transaction.Transaction{buy, sell}, 2, 0, 0},
+		{"overlapping upload", []transaction.Transaction{buy, sell}, []transaction.Transaction{sell, later}, 1, 1, 0},
+		{"identical fills", nil, []transaction.Transaction{buy, buy}, 2, 0, 0},
+		{"identical fills uploaded again", []transaction.Transaction{buy, buy}, []transaction.Transaction{buy, buy, buy}, 1, 2, 0},
+		{"changed amount", []transaction.Transaction{buy, sell}, []transaction.Transaction{buy, changed}, 0, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := importtrans.PlanMerge(tt.stored, tt.incoming)
+			if len(plan.New) != tt.new || len(plan.Duplicates) != tt.duplicates || len(plan.Conflicts) != tt.conflicts {
+				t.Errorf("Expected %d new, %d duplicates and %d conflicts, got %d, %d and %d", tt.new, tt.duplicates, tt.conflicts,
+					len(plan.New), len(plan.Duplicates), len(plan.Conflicts))
+			}
+			fingerprints := map[string]bool{}
+			for _, tran := range plan.New {
+				if fingerprints[tran.Fingerprint] {
+					t.Errorf("Expected every new row to have its own fingerprint, got %s twice", tran.Fingerprint)
+				}
+				fingerprints[tran.Fingerprint] = true
+			}
+		})
+	}
+}
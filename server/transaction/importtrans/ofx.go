@@ -0,0 +1,437 @@
+package importtrans
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/transaction"
+)
+
+const FormatOFX = "ofx"
+
+type ofxImporter struct{}
+
+func init() {
+	Register(ofxImporter{})
+}
+
+func (ofxImporter) Format() string {
+	return FormatOFX
+}
+
+// Detect looks for the OFX 1.x SGML header, the OFX 2.x processing
+// instruction or the OFX root element.
+func (ofxImporter) Detect(header []byte) bool {
+	h := bytes.ToUpper(header)
+	return bytes.Contains(h, []byte("OFXHEADER")) || bytes.Contains(h, []byte("<OFX>"))
+}
+
+// ofxNode is an element of an OFX document.  OFX 1.x leaves out the end tag
+// of elements that hold a value, so leaves carry their value and branches
+// their children.
+type ofxNode struct {
+	Name     string
+	Value    string
+	Line     int
+	Children []*ofxNode
+}
+
+func (n *ofxNode) child(name string) *ofxNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// find follows path from n, e.g. find("INVBUY", "INVTRAN", "DTTRADE").
+func (n *ofxNode) find(path ...string) *ofxNode {
+	for _, name := range path {
+		n = n.child(name)
+	}
+	return n
+}
+
+func (n *ofxNode) text(path ...string) string {
+	node := n.find(path...)
+	if node == nil {
+		return ""
+	}
+	return node.Value
+}
+
+// all returns every element called name below n.
+func (n *ofxNode) all(name string) []*ofxNode {
+	result := []*ofxNode{}
+	for _, c := range n.Children {
+		if c.Name == name {
+			result = append(result, c)
+		}
+		result = append(result, c.all(name)...)
+	}
+	return result
+}
+
+// parseOFX builds the element tree of an OFX 1.x or 2.x document, skipping
+// the headers and processing instructions in front of <OFX>.
+func parseOFX(data []byte) (*ofxNode, error) {
+	start := bytes.Index(bytes.ToUpper(data), []byte("<OFX>"))
+	if start < 0 {
+		return nil, errors.New("no <OFX> element found")
+	}
+	line := bytes.Count(data[:start], []byte("\n")) + 1
+	data = data[start:]
+
+	root := &ofxNode{}
+	stack := []*ofxNode{root}
+	// an element holding a value, or an empty one that is not an
+	// aggregate, is closed by the next tag when it has no end tag of its own
+	closeLeaf := func() {
+		top := stack[len(stack)-1]
+		if len(stack) > 1 && len(top.Children) == 0 && (top.Value != "" || !ofxAggregate(top.Name)) {
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	for len(data) > 0 {
+		lt := bytes.IndexByte(data, '<')
+		if lt < 0 {
+			lt = len(data)
+		}
+		if text := strings.TrimSpace(string(data[:lt])); text != "" {
+			top := stack[len(stack)-1]
+			top.Value = ofxUnescape(text)
+		}
+		line += bytes.Count(data[:lt], []byte("\n"))
+		data = data[lt:]
+		if len(data) == 0 {
+			break
+		}
+
+		gt := bytes.IndexByte(data, '>')
+		if gt < 0 {
+			return nil, fmt.Errorf("malformed OFX at line %d: unterminated tag", line)
+		}
+		tag := strings.TrimSpace(string(data[1:gt]))
+		tagLine := line
+		line += bytes.Count(data[:gt], []byte("\n"))
+		data = data[gt+1:]
+
+		switch {
+		case tag == "" || strings.HasPrefix(tag, "?") || strings.HasPrefix(tag, "!"):
+			continue
+		case strings.HasPrefix(tag, "/"):
+			name := strings.ToUpper(strings.TrimSpace(tag[1:]))
+			// close everything up to and including the matching element
+			idx := len(stack) - 1
+			for idx > 0 && stack[idx].Name != name {
+				idx--
+			}
+			if idx == 0 {
+				return nil, fmt.Errorf("malformed OFX at line %d: unexpected </%s>", tagLine, name)
+			}
+			stack = stack[:idx]
+		default:
+			closeLeaf()
+			name := strings.ToUpper(strings.Fields(tag)[0])
+			node := &ofxNode{Name: name, Line: tagLine}
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, node)
+			if strings.HasSuffix(tag, "/") {
+				node.Name = strings.TrimSuffix(name, "/")
+				continue
+			}
+			stack = append(stack, node)
+		}
+	}
+	ofx := root.child("OFX")
+	if ofx == nil {
+		return nil, errors.New("no <OFX> element found")
+	}
+	return ofx, nil
+}
+
+// ofxAggregates are the OFX elements that hold other elements rather than a
+// value.
+var ofxAggregates = map[string]bool{
+	"OFX": true, "SONRS": true, "STATUS": true, "FI": true,
+	"INVSTMTRS": true, "INVACCTFROM": true, "INVACCTTO": true,
+	"BUYDEBT": true, "BUYMF": true, "BUYOPT": true, "BUYOTHER": true, "BUYSTOCK": true,
+	"SELLDEBT": true, "SELLMF": true, "SELLOPT": true, "SELLOTHER": true, "SELLSTOCK": true,
+	"CLOSUREOPT": true, "INCOME": true, "INVEXPENSE": true, "JRNLFUND": true, "JRNLSEC": true,
+	"MARGININTEREST": true, "REINVEST": true, "RETOFCAP": true, "SPLIT": true, "TRANSFER": true,
+	"INVBANKTRAN": true, "STMTTRN": true, "INVBUY": true, "INVSELL": true, "INVTRAN": true,
+	"SECID": true, "CURRENCY": true, "ORIGCURRENCY": true,
+	"POSDEBT": true, "POSMF": true, "POSOPT": true, "POSOTHER": true, "POSSTOCK": true, "INVPOS": true,
+	"INVBAL": true, "BAL": true, "INV401K": true, "INV401KBAL": true,
+	"STMTRS": true, "BANKACCTFROM": true, "BANKACCTTO": true, "CCACCTFROM": true, "LEDGERBAL": true, "AVAILBAL": true,
+	"DEBTINFO": true, "MFINFO": true, "OPTINFO": true, "OTHERINFO": true, "STOCKINFO": true, "SECINFO": true,
+	"MFASSETCLASS": true, "FIMFASSETCLASS": true, "PORTION": true, "FIPORTION": true,
+}
+
+func ofxAggregate(name string) bool {
+	// message sets, transaction wrappers and lists, e.g. INVSTMTMSGSRSV1,
+	// INVSTMTTRNRS and INVTRANLIST
+	return ofxAggregates[name] || strings.HasSuffix(name, "MSGSRSV1") ||
+		strings.HasSuffix(name, "TRNRS") || strings.HasSuffix(name, "LIST")
+}
+
+func ofxUnescape(s string) string {
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&", "&quot;", "\"", "&apos;", "'", "&nbsp;", " ").Replace(s)
+}
+
+// ofxSecurity is what the SECLIST says about a SECID.
+type ofxSecurity struct {
+	Ticker string
+	Name   string
+	// options only
+	IsOption   bool
+	OptType    string
+	Strike     string
+	Expire     string
+	Underlying string
+	// SharesPerContract is what one contract delivers, 100 for standard
+	// contracts and 10 for minis.
+	SharesPerContract string
+}
+
+func ofxSecurities(ofx *ofxNode) map[string]ofxSecurity {
+	securities := map[string]ofxSecurity{}
+	for _, seclist := range ofx.all("SECLIST") {
+		for _, info := range seclist.Children {
+			secinfo := info.child("SECINFO")
+			if secinfo == nil {
+				continue
+			}
+			sec := ofxSecurity{
+				Ticker: secinfo.text("TICKER"),
+				Name:   secinfo.text("SECNAME"),
+			}
+			if info.Name == "OPTINFO" {
+				sec.IsOption = true
+				sec.OptType = info.text("OPTTYPE")
+				sec.Strike = info.text("STRIKEPRICE")
+				sec.Expire = info.text("DTEXPIRE")
+				sec.Underlying = info.text("SECID", "UNIQUEID")
+				sec.SharesPerContract = info.text("SHPERCTRCT")
+			}
+			securities[secinfo.text("SECID", "UNIQUEID")] = sec
+		}
+	}
+	return securities
+}
+
+func (ofxImporter) Parse(r io.Reader) (*ParseResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	ofx, err := parseOFX(data)
+	if err != nil {
+		return nil, err
+	}
+	securities := ofxSecurities(ofx)
+
+	result := &ParseResult{}
+	statements := ofx.all("INVSTMTRS")
+	if len(statements) == 0 {
+		return nil, errors.New("no investment statement (INVSTMTRS) found")
+	}
+	for _, stmt := range statements {
+		// a file can hold the statements of several accounts
+		account := stmt.text("INVACCTFROM", "ACCTID")
+		if result.Account == "" {
+			result.Account = account
+		}
+		tranList := stmt.child("INVTRANLIST")
+		if tranList == nil {
+			continue
+		}
+		for _, node := range tranList.Children {
+			t, err := ofxTransaction(node, securities)
+			if err != nil {
+				result.Errors = append(result.Errors, RowError{Line: node.Line, Message: err.Error()})
+				continue
+			}
+			if t != nil {
+				t.Account = truncate(account, 100)
+				result.Transactions = append(result.Transactions, *t)
+			}
+		}
+	}
+	return result, nil
+}
+
+func ofxTransaction(node *ofxNode, securities map[string]ofxSecurity) (*transaction.Transaction, error) {
+	// INVBUY and INVSELL wrap the fields shared by all buys and sells
+	detail := node
+	if c := node.child("INVBUY"); c != nil {
+		detail = c
+	}
+	if c := node.child("INVSELL"); c != nil {
+		detail = c
+	}
+
+	var action string
+	switch node.Name {
+	case "BUYSTOCK":
+		action = "Buy"
+	case "SELLSTOCK":
+		action = "Sell"
+	case "BUYOPT":
+		action = "Buy to Open"
+		if node.text("OPTBUYTYPE") == "BUYTOCLOSE" {
+			action = "Buy to Close"
+		}
+	case "SELLOPT":
+		action = "Sell to Open"
+		if node.text("OPTSELLTYPE") == "SELLTOCLOSE" {
+			action = "Sell to Close"
+		}
+	case "CLOSUREOPT":
+		switch node.text("OPTACTION") {
+		case "ASSIGN":
+			action = "Assigned"
+		case "EXPIRE":
+			action = "Expired"
+		case "EXERCISE":
+			action = "Exchange or Exercise"
+		default:
+			return nil, fmt.Errorf("unknown option action %q", node.text("OPTACTION"))
+		}
+	case "INCOME":
+		switch node.text("INCOMETYPE") {
+		case "DIV":
+			action = "Cash Dividend"
+		case "INTEREST":
+			action = "Credit Interest"
+		case "CGLONG":
+			action = "Long Term Cap Gain"
+		case "CGSHORT":
+			action = "Short Term Cap Gain"
+		default:
+			action = "Misc Cash Entry"
+		}
+	case "REINVEST":
+		action = "Reinvest Shares"
+	default:
+		// bank transactions, transfers and the like
+		return nil, nil
+	}
+
+	date, err := ofxDate(detail.text("INVTRAN", "DTTRADE"))
+	if err != nil {
+		return nil, err
+	}
+
+	secID := detail.text("SECID", "UNIQUEID")
+	sec, ok := securities[secID]
+	if !ok && secID != "" {
+		return nil, fmt.Errorf("security %s is not in the SECLIST", secID)
+	}
+	var option *transaction.Option
+	if sec.IsOption {
+		o, err := ofxOption(sec, securities)
+		if err != nil {
+			return nil, err
+		}
+		option = &o
+	}
+
+	numbers := map[string]decimal.Decimal{}
+	for _, name := range []string{"UNITS", "UNITPRICE", "COMMISSION", "FEES", "TOTAL"} {
+		value := detail.text(name)
+		d, err := safeStringToDecimal(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", strings.ToLower(name), value)
+		}
+		numbers[name] = d
+	}
+
+	description := sec.Name
+	if memo := detail.text("INVTRAN", "MEMO"); memo != "" {
+		description = memo
+	}
+	t := &transaction.Transaction{
+		Date:        date,
+		Action:      action,
+		Symbol:      sec.Ticker,
+		Description: truncate(description, 250),
+		// sells carry negative units
+		Quantity: numbers["UNITS"].Abs().InexactFloat64(),
+		Price:    numbers["UNITPRICE"],
+		FeesComm: numbers["COMMISSION"].Add(numbers["FEES"]),
+		Amount:   numbers["TOTAL"],
+	}
+	if option != nil {
+		t.SetContract(*option)
+	}
+	if err := t.Classify(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ofxOption reads the contract of an option from its OPTINFO, falling back
+// on its ticker, and sizes it by the shares per contract.
+func ofxOption(sec ofxSecurity, securities map[string]ofxSecurity) (transaction.Option, error) {
+	underlying := securities[sec.Underlying].Ticker
+	expire, dateErr := ofxDate(sec.Expire)
+	strike, strikeErr := safeStringToDecimal(sec.Strike)
+	putCall := ""
+	switch sec.OptType {
+	case "CALL":
+		putCall = "C"
+	case "PUT":
+		putCall = "P"
+	}
+
+	var o transaction.Option
+	if underlying != "" && dateErr == nil && strikeErr == nil && sec.Strike != "" && putCall != "" {
+		o = transaction.Option{
+			Root:       underlying,
+			Underlying: underlying,
+			Expiry:     expire,
+			Strike:     strike,
+			PutCall:    putCall,
+			Multiplier: transaction.StandardMultiplier,
+		}
+	} else if occ, ok := transaction.ParseOption(sec.Ticker); ok {
+		o = occ
+	} else {
+		return transaction.Option{}, fmt.Errorf("can't tell the option contract of %q", sec.Ticker)
+	}
+
+	shares, err := safeStringToDecimal(sec.SharesPerContract)
+	if err != nil {
+		return transaction.Option{}, fmt.Errorf("invalid shares per contract %q", sec.SharesPerContract)
+	}
+	if shares.IsPositive() {
+		o.Multiplier = shares.InexactFloat64()
+		o.Deliverable = 0
+		if o.Multiplier != transaction.StandardDeliverable {
+			o.Deliverable = o.Multiplier
+		}
+	}
+	return o, nil
+}
+
+// ofxDate reads the date part of 20240102, 20240102120000 or
+// 20240102120000.000[-5:EST].
+func ofxDate(value string) (time.Time, error) {
+	if len(value) >= 8 {
+		if t, err := time.Parse("20060102", value[:8]); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
@@ -0,0 +1,109 @@
+package importtrans_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/transaction/importtrans"
+)
+
+func parseOFXFile(t *testing.T, name string) *importtrans.ParseResult {
+	f, err := os.Open("../../../test_data/" + name)
+	if err != nil {
+		t.Fatalf("Unable to open file ./test_data/%s", name)
+	}
+	defer f.Close()
+
+	imp, r, err := importtrans.DetectReader(name, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imp.Format() != importtrans.FormatOFX {
+		t.Fatalf("Expected OFX importer, got %s", imp.Format())
+	}
+	result, err := imp.Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func checkTransactions(t *testing.T, expected []transaction.Transaction, got []transaction.Transaction) {
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d transactions, got %d", len(expected), len(got))
+	}
+	for i, tran := range got {
+		e := expected[i]
+		if tran.Date != e.Date || tran.Action != e.Action || tran.Symbol != e.Symbol || tran.Quantity != e.Quantity ||
+			!tran.Price.Equal(e.Price) || !tran.FeesComm.Equal(e.FeesComm) || !tran.Amount.Equal(e.Amount) {
+			t.Errorf("Expected %v but got %v", e, tran)
+		}
+	}
+}
+
+func TestParseOFXSGML(t *testing.T) {
+	result := parseOFXFile(t, "ofxStatement.ofx")
+	if result.Account != "XXXX4321" {
+		t.Errorf("Expected account XXXX4321, got %q", result.Account)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Line != 109 {
+		t.Errorf("Expected an error on line 109, got %v", result.Errors)
+	}
+	checkTransactions(t, []transaction.Transaction{
+		{Date: date("01/02/2024"), Action: "Buy", Symbol: "AAPL", Quantity: 100, Price: dec("185.5"), Amount: dec("-18550")},
+		{Date: date("01/03/2024"), Action: "Sell to Open", Symbol: "AAPL 01/19/2024 190.00 C", Quantity: 1, Price: dec("1.5"), FeesComm: dec("0.66"), Amount: dec("149.34")},
+		{Date: date("01/19/2024"), Action: "Assigned", Symbol: "AAPL 01/19/2024 190.00 C", Quantity: 1},
+		{Date: date("01/19/2024"), Action: "Sell", Symbol: "AAPL", Quantity: 100, Price: dec("190"), FeesComm: dec("0.03"), Amount: dec("18999.97")},
+		{Date: date("01/15/2024"), Action: "Cash Dividend", Symbol: "AAPL", Amount: dec("24")},
+	}, result.Transactions)
+}
+
+func TestParseOFXXML(t *testing.T) {
+	result := parseOFXFile(t, "ofxStatement.qfx")
+	if result.Account != "XXXX8765" {
+		t.Errorf("Expected account XXXX8765, got %q", result.Account)
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("Expected no errors, got %v", result.Errors)
+	}
+	checkTransactions(t, []transaction.Transaction{
+		{Date: date("01/02/2024"), Action: "Sell to Open", Symbol: "SPY 01/19/2024 470.00 P", Quantity: 2, Price: dec("1.05"), FeesComm: dec("1.3"), Amount: dec("208.7")},
+		{Date: date("01/19/2024"), Action: "Expired", Symbol: "SPY 01/19/2024 470.00 P", Quantity: 2},
+		{Date: date("01/25/2024"), Action: "Reinvest Shares", Symbol: "SPY", Quantity: 0.1, Price: dec("475"), Amount: dec("-47.5")},
+		{Date: date("01/05/2024"), Action: "Buy", Symbol: "SPY", Quantity: 10, Price: dec("468"), Amount: dec("-4680")},
+		{Date: date("01/08/2024"), Action: "Sell to Open", Symbol: "AMZN 01/19/2024 150.00 C", Quantity: 3, Price: dec("2.4"), FeesComm: dec("1.95"), Amount: dec("70.05")},
+	}, result.Transactions)
+	for i, tran := range result.Transactions {
+		account := "XXXX8765"
+		if i >= 3 {
+			account = "XXXX2468"
+		}
+		if tran.Account != account {
+			t.Errorf("Expected %v in account %s, got %q", tran, account, tran.Account)
+		}
+	}
+
+	if o, ok := result.Transactions[0].Contract(); !ok || o.SharesPerContract() != 100 || o.Multiplier != 100 {
+		t.Errorf("Expected a standard contract, got %+v", o)
+	}
+	mini, ok := result.Transactions[4].Contract()
+	if !ok || mini.Underlying != "AMZN" || mini.SharesPerContract() != 10 || mini.Multiplier != 10 {
+		t.Errorf("Expected a 10 share AMZN mini, got %+v", mini)
+	}
+}
+
+func TestParseOFXEmptyLeaf(t *testing.T) {
+	// the empty COMMISSION has no end tag, so the fields after it must not
+	// end up inside it
+	result := parseOFXFile(t, "ofxEmptyLeaf.ofx")
+	if len(result.Errors) != 0 {
+		t.Errorf("Expected no errors, got %v", result.Errors)
+	}
+	checkTransactions(t, []transaction.Transaction{
+		{Date: date("01/02/2024"), Action: "Buy", Symbol: "AAPL", Quantity: 100, Price: dec("185.5"), FeesComm: dec("0.05"), Amount: dec("-18550.05")},
+	}, result.Transactions)
+	if d := result.Transactions[0].Description; d != "APPLE INC" {
+		t.Errorf("Expected the security name for an empty memo, got %q", d)
+	}
+}
@@ -0,0 +1,124 @@
+package importtrans
+
+import (
+	"io"
+
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/corporateaction"
+	"github.com/wazupwiddat/postrack/server/split"
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+type PreviewUpload struct {
+	Name   string
+	Reader io.Reader
+}
+
+type PreviewRequest struct {
+	User    *user.User
+	Uploads []PreviewUpload
+}
+
+type PreviewFile struct {
+	Name         string
+	Format       string
+	Account      string
+	Transactions []transaction.Transaction
+	Duplicates   []transaction.Transaction
+	Conflicts    []transaction.Transaction
+	Errors       []RowError
+	Error        string
+}
+
+type PreviewResponse struct {
+	Files []PreviewFile
+	// Positions are the positions the new rows would open or change.
+	Positions transaction.Positions
+}
+
+// Preview parses the uploads exactly like an import would, but only reports
+// what the import would store.
+func Preview(db *gorm.DB, req *PreviewRequest) (*PreviewResponse, error) {
+	aliases, err := accountalias.FindAllByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &PreviewResponse{}
+	// the rows earlier files would store
+	planned := []transaction.Transaction{}
+	newFingerprints := map[string]bool{}
+	for _, upload := range req.Uploads {
+		file := PreviewFile{
+			Name: SanitizeFilename(upload.Name),
+		}
+		imp, r, err := DetectReader(file.Name, upload.Reader)
+		if err != nil {
+			file.Error = err.Error()
+			response.Files = append(response.Files, file)
+			continue
+		}
+		file.Format = imp.Format()
+		parsed, err := imp.Parse(r)
+		if err != nil {
+			file.Error = err.Error()
+			response.Files = append(response.Files, file)
+			continue
+		}
+
+		file.Account = aliases.Resolve(file.Name, parsed.Account)
+		transactions := parsed.Transactions
+		for idx := range transactions {
+			transactions[idx].UserID = req.User.ID
+			if transactions[idx].Account == "" {
+				transactions[idx].Account = file.Account
+			} else {
+				transactions[idx].Account = aliases.ResolveAccount(transactions[idx].Account)
+			}
+		}
+
+		// the same stored rows an import merges against
+		stored, err := findStored(db, req.User, transactions)
+		if err != nil {
+			return nil, err
+		}
+		plan := PlanMerge(append(stored, planned...), transactions)
+		file.Transactions = plan.New
+		file.Duplicates = plan.Duplicates
+		file.Conflicts = plan.Conflicts
+		file.Errors = parsed.Errors
+		response.Files = append(response.Files, file)
+
+		// later files are checked against this one as if it had been stored
+		planned = append(planned, plan.New...)
+		for _, tran := range plan.New {
+			newFingerprints[tran.Fingerprint] = true
+		}
+	}
+
+	splits, err := split.FindConfirmed(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := corporateaction.FindApplied(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := transaction.FindAllByUser(db, req.User)
+	if err != nil {
+		return nil, err
+	}
+	all := transaction.Transactions(append(stored, planned...))
+	positions := all.MergeTransactions(splits, actions).CollectPositions()
+	response.Positions = positions.Filter(func(pos transaction.Position) bool {
+		for _, tran := range pos.Transactions {
+			if newFingerprints[tran.Fingerprint] {
+				return true
+			}
+		}
+		return false
+	})
+	return response, nil
+}
@@ -0,0 +1,395 @@
+package importtrans
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/brokeraccount"
+	"github.com/wazupwiddat/postrack/server/config"
+	"github.com/wazupwiddat/postrack/server/importbatch"
+	"github.com/wazupwiddat/postrack/server/schwab"
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/user"
+	schwabClient "github.com/wazupwiddat/schwab-api/client"
+	"github.com/wazupwiddat/schwab-api/models"
+	"gorm.io/gorm"
+)
+
+const FormatSchwabAPI = "schwab-api"
+
+// schwabSyncTypes are the Trader API transaction types that carry trades,
+// option assignments, expirations and splits.
+var schwabSyncTypes = []string{"TRADE", "RECEIVE_AND_DELIVER"}
+
+// syncOverlap is how far before the last sync an incremental sync starts, to
+// pick up rows the broker posted late.  Merge skips the ones already stored.
+const syncOverlap = 3 * 24 * time.Hour
+
+type SchwabSyncRequest struct {
+	User  *user.User
+	Start time.Time
+	End   time.Time
+	// Incremental starts each account where its last sync left off, and at
+	// Start only when it has never been synced.
+	Incremental bool
+}
+
+type SchwabSyncResponse struct {
+	BatchID     uint
+	Accounts    int
+	Fetched     int
+	Inserted    int
+	Skipped     int
+	Conflicting int
+	Errors      []RowError
+}
+
+// SchwabAccountTransactions are the mapped rows for one linked account.
+type SchwabAccountTransactions struct {
+	Hash         string
+	Account      string
+	Transactions []transaction.Transaction
+	Errors       []RowError
+}
+
+// SyncSchwabTransactions fetches the transaction history of every linked
+// Schwab account between Start and End and merges it like an import.
+func SyncSchwabTransactions(db *gorm.DB, cfg *config.Config, req *SchwabSyncRequest) (*SchwabSyncResponse, error) {
+	token, err := schwab.FindActiveByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	states, err := schwab.FindSyncStatesByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	since := map[string]time.Time{}
+	if req.Incremental {
+		for _, state := range states {
+			since[state.AccountHash] = state.LastSyncedAt.Add(-syncOverlap)
+		}
+	}
+
+	sClient := schwabClient.NewSchwabClient(cfg.Schwab.ClientID, cfg.Schwab.ClientSecret, cfg.Schwab.AuthRedirect)
+	accounts, err := FetchSchwabTransactions(sClient, token.AccessToken, req.Start, req.End, since)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := accountalias.FindAllByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := brokeraccount.Relink(db, req.User.ID, aliases); err != nil {
+		log.Println(err)
+	}
+
+	response := &SchwabSyncResponse{Accounts: len(accounts)}
+	incoming := []transaction.Transaction{}
+	for _, acct := range accounts {
+		accountName := aliases.Resolve(acct.Account)
+		for _, tran := range acct.Transactions {
+			tran.Account = accountName
+			incoming = append(incoming, tran)
+		}
+		response.Errors = append(response.Errors, acct.Errors...)
+	}
+	response.Fetched = len(incoming)
+
+	result, batchID, err := mergeSchwabTransactions(db, req.User, incoming)
+	if err != nil {
+		return nil, err
+	}
+	recordSyncStates(db, req, states, accounts)
+	proposeSplits(db, req.User)
+
+	response.BatchID = batchID
+	response.Inserted = result.Inserted
+	response.Skipped = result.Skipped
+	response.Conflicting = result.Conflicting
+	return response, nil
+}
+
+// mergeSchwabTransactions merges the synced rows under a new import batch.
+// A sync that stores nothing, as most nightly ones do, leaves no batch to
+// list or undo, and its batch ID is 0.
+func mergeSchwabTransactions(db *gorm.DB, u *user.User, incoming []transaction.Transaction) (*MergeResult, uint, error) {
+	if len(incoming) == 0 {
+		return &MergeResult{}, 0, nil
+	}
+	batch := &importbatch.ImportBatch{
+		UserID: u.ID,
+		Format: FormatSchwabAPI,
+	}
+	if _, err := importbatch.Create(db, batch); err != nil {
+		return nil, 0, err
+	}
+	for idx := range incoming {
+		incoming[idx].ImportBatchID = batch.ID
+	}
+
+	result, err := Merge(db, u, incoming)
+	if err != nil {
+		return nil, 0, err
+	}
+	if result.Inserted == 0 {
+		if err := importbatch.Delete(db, batch); err != nil {
+			log.Println(err)
+		}
+		return result, 0, nil
+	}
+	finishBatch(db, batch, result)
+	return result, batch.ID, nil
+}
+
+// FetchSchwabTransactions pulls the trade history of every account the token
+// can see and maps it into transactions, keyed by the masked account number.
+// since holds a later start for the accounts, by hash, that need less.
+func FetchSchwabTransactions(sClient *schwabClient.SchwabAPIClient, accessToken string,
+	start time.Time, end time.Time, since map[string]time.Time) ([]SchwabAccountTransactions, error) {
+	numbers, err := schwab.GetAccountNumbers(sClient, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := []SchwabAccountTransactions{}
+	for _, number := range numbers {
+		acct := SchwabAccountTransactions{
+			Hash:    number.HashValue,
+			Account: schwab.AccountMask(number.AccountNumber),
+		}
+		from := start
+		if s, ok := since[number.HashValue]; ok && s.After(from) {
+			from = s
+		}
+		for _, types := range schwabSyncTypes {
+			raw, err := getSchwabTransactions(sClient, accessToken, number.HashValue, types, from, end)
+			if err != nil {
+				return nil, err
+			}
+			trans, rowErrors := SchwabTransactions(raw)
+			acct.Transactions = append(acct.Transactions, trans...)
+			acct.Errors = append(acct.Errors, rowErrors...)
+		}
+		sortTransactionsByDate(acct.Transactions)
+		accounts = append(accounts, acct)
+	}
+	return accounts, nil
+}
+
+// recordSyncStates moves each fetched account's last synced time up to the
+// end of the request.
+func recordSyncStates(db *gorm.DB, req *SchwabSyncRequest, states []schwab.SchwabSyncState,
+	accounts []SchwabAccountTransactions) {
+	byHash := map[string]schwab.SchwabSyncState{}
+	for _, state := range states {
+		byHash[state.AccountHash] = state
+	}
+	for _, acct := range accounts {
+		state, ok := byHash[acct.Hash]
+		if !ok {
+			state = schwab.SchwabSyncState{UserID: req.User.ID, AccountHash: acct.Hash}
+		}
+		if !req.End.After(state.LastSyncedAt) {
+			continue
+		}
+		state.Account = acct.Account
+		state.LastSyncedAt = req.End
+		if _, err := schwab.UpdateSyncState(db, &state); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// accounts/{accountNumber}/transactions
+func getSchwabTransactions(sClient *schwabClient.SchwabAPIClient, accessToken string, accountHash string,
+	types string, start time.Time, end time.Time) (models.Transactions, error) {
+	query := url.Values{}
+	query.Set("startDate", start.UTC().Format("2006-01-02T15:04:05.000Z"))
+	query.Set("endDate", end.UTC().Format("2006-01-02T15:04:05.000Z"))
+	query.Set("types", types)
+	endpoint := fmt.Sprintf("%s/accounts/%s/transactions?%s", sClient.TraderBaseURL, url.PathEscape(accountHash), query.Encode())
+
+	var trans models.Transactions
+	err := schwab.GetJSON(sClient, accessToken, endpoint, &trans)
+	return trans, err
+}
+
+// SchwabTransactions maps Trader API transactions into rows shaped like the
+// Schwab CSV export.  Entries that are not trades, assignments, expirations
+// or splits are skipped; ones that cannot be read are reported by their
+// position in the response.
+func SchwabTransactions(raw models.Transactions) ([]transaction.Transaction, []RowError) {
+	trans := []transaction.Transaction{}
+	rowErrors := []RowError{}
+	for idx, st := range raw {
+		tran, err := schwabTransaction(st.Type, st.TradeDate, st.Time, st.Description, st.NetAmount, st.TransferItems)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{
+				Line:    idx + 1,
+				Message: fmt.Sprintf("activity %d: %s", st.ActivityID, err),
+			})
+			continue
+		}
+		if tran == nil {
+			continue
+		}
+		trans = append(trans, *tran)
+	}
+	return trans, rowErrors
+}
+
+func schwabTransaction(kind string, tradeDate string, activityTime string, description string,
+	netAmount float64, items []models.TransferItem) (*transaction.Transaction, error) {
+	date, err := schwabAPIDate(firstNonEmpty(tradeDate, activityTime))
+	if err != nil {
+		return nil, err
+	}
+
+	var item *models.TransferItem
+	fees := decimal.Zero
+	for idx, ti := range items {
+		if ti.FeeType != "" {
+			fees = fees.Add(decimal.NewFromFloat(ti.Cost).Abs())
+			continue
+		}
+		if ti.Instrument.AssetType != "CURRENCY" && item == nil {
+			item = &items[idx]
+		}
+	}
+	if item == nil {
+		return nil, nil
+	}
+
+	tran := &transaction.Transaction{
+		Date:        date,
+		Description: truncate(firstNonEmpty(item.Instrument.Description, description), 250),
+		Quantity:    math.Abs(item.Amount),
+	}
+
+	isOption := item.Instrument.AssetType == "OPTION"
+	if isOption {
+		o, err := schwabOption(item.Instrument)
+		if err != nil {
+			return nil, err
+		}
+		tran.SetContract(o)
+	} else {
+		tran.Symbol = item.Instrument.Symbol
+	}
+
+	switch kind {
+	case "TRADE":
+		tran.Price = decimal.NewFromFloat(item.Price)
+		tran.FeesComm = fees.Round(2)
+		tran.Amount = decimal.NewFromFloat(netAmount).Round(2)
+		side := "Buy"
+		if item.Amount < 0 {
+			side = "Sell"
+		}
+		tran.Action = side
+		if isOption {
+			openClose := "Open"
+			if item.PositionEffect == "CLOSING" {
+				openClose = "Close"
+			}
+			tran.Action = side + " to " + openClose
+		}
+	case "RECEIVE_AND_DELIVER":
+		desc := strings.ToUpper(description)
+		switch {
+		case isOption && strings.Contains(desc, "ASSIGN"):
+			tran.Action = "Assigned"
+		case isOption && strings.Contains(desc, "EXERCI"):
+			tran.Action = "Exchange or Exercise"
+		case isOption && strings.Contains(desc, "EXPIR"):
+			tran.Action = "Expired"
+		case !isOption && strings.Contains(desc, "SPLIT"):
+			tran.Action = "Stock Split"
+		default:
+			// transfers in and out are not trades
+			return nil, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %q", kind)
+	}
+	if tran.Symbol == "" {
+		return nil, fmt.Errorf("missing symbol")
+	}
+	if err := tran.Classify(); err != nil {
+		return nil, err
+	}
+	return tran, nil
+}
+
+// schwabOption is the contract of an option instrument, sized by the
+// premium multiplier and deliverables the API sends with it.
+func schwabOption(inst models.TransactionInstrument) (transaction.Option, error) {
+	o, ok := transaction.ParseOption(inst.Symbol)
+	if !ok {
+		expiry, err := schwabAPIDate(inst.ExpirationDate)
+		if err != nil {
+			return transaction.Option{}, fmt.Errorf("invalid option symbol %q", inst.Symbol)
+		}
+		putCall := inst.PutCall[:min(len(inst.PutCall), 1)]
+		if inst.UnderlyingSymbol == "" || (putCall != "C" && putCall != "P") {
+			return transaction.Option{}, fmt.Errorf("invalid option symbol %q", inst.Symbol)
+		}
+		o = transaction.Option{
+			Root:       inst.UnderlyingSymbol,
+			Underlying: inst.UnderlyingSymbol,
+			Expiry:     expiry,
+			Strike:     decimal.NewFromFloat(inst.StrikePrice),
+			PutCall:    putCall,
+			Multiplier: transaction.StandardMultiplier,
+		}
+	}
+	if inst.UnderlyingSymbol != "" {
+		o.Underlying = inst.UnderlyingSymbol
+	}
+	if inst.OptionPremiumMultiplier > 0 {
+		o.Multiplier = float64(inst.OptionPremiumMultiplier)
+	}
+	for _, d := range inst.OptionDeliverables {
+		if d.Deliverable.Symbol == o.Underlying && d.DeliverableUnits > 0 {
+			o.Deliverable = float64(d.DeliverableUnits)
+		}
+	}
+	return o, nil
+}
+
+// schwabAPIDate reads the ISO-8601 times the Trader API uses, such as
+// 2024-01-02T14:30:00+0000, as the trade date in Schwab's own time zone.
+func schwabAPIDate(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05-0700", "2006-01-02T15:04:05.000-0700", time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return transaction.Day(t.In(schwabLocation)), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+var schwabLocation = loadLocation("America/New_York")
+
+func loadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Println(err)
+		return time.UTC
+	}
+	return loc
+}
+
+func sortTransactionsByDate(trans []transaction.Transaction) {
+	sort.SliceStable(trans, func(i, j int) bool {
+		return trans[i].Date.Before(trans[j].Date)
+	})
+}
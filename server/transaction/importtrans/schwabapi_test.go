@@ -0,0 +1,159 @@
+package importtrans_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/transaction/importtrans"
+	schwabClient "github.com/wazupwiddat/schwab-api/client"
+)
+
+// newSchwabStandIn serves the Trader API endpoints the sync uses from the
+// files in test_data.
+// The start date of every transactions request is added to starts.
+func newSchwabStandIn(t *testing.T, starts *[]string) *httptest.Server {
+	fixtures := map[string]string{
+		"TRADE":               "../../../test_data/schwabApiTrades.json",
+		"RECEIVE_AND_DELIVER": "../../../test_data/schwabApiReceiveAndDeliver.json",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/accounts/accountNumbers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`[{"accountNumber": "12345678", "hashValue": "HASH1"}]`))
+	})
+	mux.HandleFunc("/accounts/HASH1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("endDate") != "2024-01-31T00:00:00.000Z" {
+			t.Errorf("Unexpected end date %s", q.Get("endDate"))
+		}
+		*starts = append(*starts, q.Get("startDate"))
+		fixture, ok := fixtures[q.Get("types")]
+		if !ok {
+			http.Error(w, `{"message": "bad types"}`, http.StatusBadRequest)
+			return
+		}
+		http.ServeFile(w, r, fixture)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestFetchSchwabTransactions(t *testing.T) {
+	starts := []string{}
+	server := newSchwabStandIn(t, &starts)
+	defer server.Close()
+
+	sClient := schwabClient.NewSchwabClient("client", "secret", "")
+	sClient.TraderBaseURL = server.URL
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	accounts, err := importtrans.FetchSchwabTransactions(sClient, "test-token", start, end, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != 2 || starts[0] != "2024-01-01T00:00:00.000Z" {
+		t.Errorf("Expected both requests to start 01/01/2024, got %v", starts)
+	}
+	if len(accounts) != 1 || accounts[0].Account != "...678" || accounts[0].Hash != "HASH1" {
+		t.Fatalf("Expected one account ...678, got %v", accounts)
+	}
+	acct := accounts[0]
+	if len(acct.Errors) != 1 || acct.Errors[0].Line != 3 {
+		t.Errorf("Expected an error for the third trade, got %v", acct.Errors)
+	}
+
+	expected := []transaction.Transaction{
+		{Date: date("01/02/2024"), Action: "Buy", Symbol: "AAPL", Quantity: 100, Price: dec("185.5"), Amount: dec("-18550")},
+		{Date: date("01/03/2024"), Action: "Sell to Open", Symbol: "AAPL 01/19/2024 190.00 C", Quantity: 1, Price: dec("1.5"), FeesComm: dec("0.66"), Amount: dec("149.34")},
+		{Date: date("01/19/2024"), Action: "Assigned", Symbol: "AAPL 01/19/2024 190.00 C", Quantity: 1},
+	}
+	checkTransactions(t, expected, acct.Transactions)
+}
+
+func TestFetchSchwabTransactionsSince(t *testing.T) {
+	starts := []string{}
+	server := newSchwabStandIn(t, &starts)
+	defer server.Close()
+
+	sClient := schwabClient.NewSchwabClient("client", "secret", "")
+	sClient.TraderBaseURL = server.URL
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	since := map[string]time.Time{"HASH1": time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)}
+	if _, err := importtrans.FetchSchwabTransactions(sClient, "test-token", start, end, since); err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != 2 || starts[0] != "2024-01-15T00:00:00.000Z" || starts[1] != starts[0] {
+		t.Errorf("Expected only the delta from 01/15/2024 to be fetched, got %v", starts)
+	}
+}
+
+func TestFetchSchwabTransactionsAPIError(t *testing.T) {
+	starts := []string{}
+	server := newSchwabStandIn(t, &starts)
+	defer server.Close()
+
+	sClient := schwabClient.NewSchwabClient("client", "secret", "")
+	sClient.TraderBaseURL = server.URL + "/missing"
+
+	_, err := importtrans.FetchSchwabTransactions(sClient, "test-token", time.Now().AddDate(0, 0, -1), time.Now(), nil)
+	if err == nil {
+		t.Fatal("Expected an error from the Schwab API")
+	}
+}
+
+func TestSchwabCSVAndAPIAreOneAccount(t *testing.T) {
+	csv := `"Transactions  for account XXXX-5678 as of 02/04/2024 20:30:11 ET"
+"Date","Action","Symbol","Description","Quantity","Price","Fees & Comm","Amount",
+"01/02/2024","Buy","AAPL","APPLE INC","100","$185.50","","-$18,550.00",
+`
+	imp, r, err := importtrans.DetectReader("XXX678_Transactions_20240204-203011.csv", strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := imp.Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliases := accountalias.Aliases{}
+	stored := parsed.Transactions
+	for idx := range stored {
+		stored[idx].Account = aliases.Resolve("XXX678_Transactions_20240204-203011.csv", parsed.Account)
+	}
+
+	starts := []string{}
+	server := newSchwabStandIn(t, &starts)
+	defer server.Close()
+	sClient := schwabClient.NewSchwabClient("client", "secret", "")
+	sClient.TraderBaseURL = server.URL
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	accounts, err := importtrans.FetchSchwabTransactions(sClient, "test-token", start, end, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	incoming := []transaction.Transaction{}
+	for _, tran := range accounts[0].Transactions {
+		tran.Account = aliases.Resolve(accounts[0].Account)
+		incoming = append(incoming, tran)
+	}
+
+	plan := importtrans.PlanMerge(stored, incoming)
+	if len(plan.Duplicates) != 1 || plan.Duplicates[0].Symbol != "AAPL" || plan.Duplicates[0].Account != "...678" {
+		t.Errorf("Expected the AAPL buy to already be stored under ...678, got %v", plan.Duplicates)
+	}
+	for _, tran := range plan.New {
+		if tran.Symbol == "AAPL" && tran.Action == "Buy" {
+			t.Errorf("Expected the AAPL buy to be stored once, got %v", tran)
+		}
+	}
+}
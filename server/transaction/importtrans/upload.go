@@ -0,0 +1,82 @@
+package importtrans
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/wazupwiddat/postrack/server/config"
+	"github.com/wazupwiddat/postrack/server/importjob"
+)
+
+const defaultUploadPath = "./uploads"
+
+// UploadDir is where the files uploaded for a job are kept until the job has
+// read them; every user and every job get their own directory.
+func UploadDir(cfg *config.Config, job *importjob.ImportJob) string {
+	root := cfg.Import.DownloadPath
+	if root == "" {
+		root = defaultUploadPath
+	}
+	return filepath.Join(root, strconv.FormatUint(uint64(job.UserID), 10), strconv.FormatUint(uint64(job.ID), 10))
+}
+
+// SaveUpload stores an uploaded file in the job's upload directory and
+// returns the name it was stored under.
+func SaveUpload(cfg *config.Config, job *importjob.ImportJob, filename string, r io.Reader) (string, error) {
+	dir := UploadDir(cfg, job)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	name := SanitizeFilename(filename)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			// same name uploaded twice in one job
+			name = fmt.Sprintf("%s-%d%s", stem, i, ext)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(f, r); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+}
+
+// RemoveUploads deletes the job's upload directory and everything in it.
+func RemoveUploads(cfg *config.Config, job *importjob.ImportJob) error {
+	return os.RemoveAll(UploadDir(cfg, job))
+}
+
+// SanitizeFilename reduces a client supplied file name to a plain base name
+// so it can't point outside the upload directory.
+func SanitizeFilename(filename string) string {
+	name := filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		return "upload"
+	}
+	return name
+}
@@ -0,0 +1,25 @@
+package importtrans_test
+
+import (
+	"testing"
+
+	"github.com/wazupwiddat/postrack/server/transaction/importtrans"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	cases := map[string]string{
+		"XXX953_Transactions_20230204.json": "XXX953_Transactions_20230204.json",
+		"../../etc/passwd":                  "passwd",
+		"..\\..\\config.yml":                "config.yml",
+		"/abs/path/file.csv":                "file.csv",
+		"..":                                "upload",
+		".hidden":                           "hidden",
+		"my file (1).csv":                   "my_file__1_.csv",
+		"":                                  "upload",
+	}
+	for in, expected := range cases {
+		if got := importtrans.SanitizeFilename(in); got != expected {
+			t.Errorf("SanitizeFilename(%q) = %q, expected %q", in, got, expected)
+		}
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"log"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/piquette/finance-go"
 	"github.com/piquette/finance-go/quote"
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/corporateaction"
+	"github.com/wazupwiddat/postrack/server/split"
 	"github.com/wazupwiddat/postrack/server/transaction"
 	"github.com/wazupwiddat/postrack/server/user"
 	"gorm.io/gorm"
@@ -27,12 +31,12 @@ type InspectSymbolRequest struct {
 }
 
 type InspectSymbolResponse struct {
-	DateFrom     string
+	DateFrom     time.Time
 	Quote        finance.Quote
-	Premium      float64
-	OpenPremium  float64
-	CostBasis    float64
-	Quantity     float64
+	Premium      decimal.Decimal
+	OpenPremium  decimal.Decimal
+	CostBasis    decimal.Decimal
+	Quantity     decimal.Decimal
 	Positions    []transaction.Position
 	Assigned     []transaction.Transaction
 	Transactions []transaction.Transaction
@@ -43,8 +47,16 @@ func Inspect(db *gorm.DB, req *InspectRequest) (*InspectResponse, error) {
 	if err != nil {
 		return nil, err
 	}
+	splits, err := split.FindConfirmed(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := corporateaction.FindApplied(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
 	t := transaction.Transactions(trans)
-	mergedTransactions := t.MergeTransactions()
+	mergedTransactions := t.MergeTransactions(splits, actions)
 	positions := mergedTransactions.CollectPositions()
 
 	// Accounts with positions
@@ -66,6 +78,14 @@ func InspectSymbol(db *gorm.DB, req *InspectSymbolRequest) (*InspectSymbolRespon
 	if err != nil {
 		return nil, err
 	}
+	splits, err := split.FindConfirmed(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := corporateaction.FindApplied(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
 	acct, sym := parseSymbol(req.Symbol)
 	q, err := quote.Get(sym)
 	log.Println(err)
@@ -73,13 +93,18 @@ func InspectSymbol(db *gorm.DB, req *InspectSymbolRequest) (*InspectSymbolRespon
 	trans := transaction.Transactions(t)
 	sort.Sort(transaction.ByDate(trans))
 
-	// filter transactions to just be the selected symbol
+	// filter transactions to just be the selected symbol, and the ones
+	// that became it
+	symbols := map[string]bool{}
+	for _, s := range actions.Predecessors(sym) {
+		symbols[s] = true
+	}
 	trans = *trans.Filter(func(pos transaction.Transaction) bool {
 		return pos.Account == acct &&
-			transaction.SymbolFromOptionSymbol(pos.Symbol) == sym
+			symbols[transaction.SymbolFromOptionSymbol(pos.Symbol)]
 	})
 	dateFrom := trans[0].Date
-	mergedTransactions := trans.MergeTransactions()
+	mergedTransactions := trans.MergeTransactions(splits, actions)
 	positions := mergedTransactions.CollectPositions()
 
 	// Sum all premiums (of the transactions we have)
@@ -104,15 +129,13 @@ func InspectSymbol(db *gorm.DB, req *InspectSymbolRequest) (*InspectSymbolRespon
 			return false
 		}
 		for _, assignedPos := range assignedPositions {
-			ps := transaction.ParseOptionSymbol(assignedPos.Symbol)
-			if ps == nil {
+			o, ok := assignedPos.Transactions[0].Contract()
+			if !ok {
 				continue
 			}
-			// log.Println(trans)
-			// log.Println(ps)
-			match := trans.Symbol == ps.Symbol &&
-				trans.Price == ps.Price &&
-				((trans.Action == "Sell" && ps.OptionType == "C") || (trans.Action == "Buy" && ps.OptionType == "P"))
+			match := trans.Symbol == o.Underlying &&
+				trans.Price.Equal(o.Strike) &&
+				((trans.ActionType() == transaction.ActionSell && o.PutCall == "C") || (trans.ActionType() == transaction.ActionBuy && o.PutCall == "P"))
 			if match {
 				return true
 			}
@@ -121,16 +144,16 @@ func InspectSymbol(db *gorm.DB, req *InspectSymbolRequest) (*InspectSymbolRespon
 	})
 
 	// Sum cost basis and quantity
-	assignedCollectedPositions := assignedTransactions.MergeTransactions().CollectPositions()
+	assignedCollectedPositions := assignedTransactions.MergeTransactions(splits, actions).CollectPositions()
 	costBasis := assignedCollectedPositions.SumProduct(transaction.PositionSummerAmount)
 	quant := assignedPositions.SumProduct(func(pos transaction.Position, sum *transaction.SumProduct) {
 		for _, tran := range pos.Transactions {
-			if tran.Action == "Assigned" {
-				sp := transaction.ParseOptionSymbol(tran.Symbol)
-				if sp.OptionType == "C" {
-					sum.Value = sum.Value - (tran.Quantity * 100)
+			if tran.ActionType() == transaction.ActionAssigned {
+				o, _ := tran.Contract()
+				if o.PutCall == "C" {
+					sum.Value = sum.Value.Sub(decimal.NewFromFloat(tran.Quantity * tran.SharesPerContract()))
 				} else {
-					sum.Value = sum.Value + (tran.Quantity * 100)
+					sum.Value = sum.Value.Add(decimal.NewFromFloat(tran.Quantity * tran.SharesPerContract()))
 				}
 
 			}
@@ -142,7 +165,7 @@ func InspectSymbol(db *gorm.DB, req *InspectSymbolRequest) (*InspectSymbolRespon
 		if transaction.IsOption(trans) {
 			return false
 		}
-		return trans.Action == "Sell" || trans.Action == "Buy"
+		return trans.ActionType() == transaction.ActionSell || trans.ActionType() == transaction.ActionBuy
 	})
 
 	// Compare that against the current price
@@ -0,0 +1,308 @@
+package transaction
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// LotMethod is how the closing transactions of an account pick the lots
+// they close.
+type LotMethod string
+
+const (
+	// LotFIFO closes the oldest lot first.
+	LotFIFO LotMethod = "fifo"
+	// LotLIFO closes the newest lot first.
+	LotLIFO LotMethod = "lifo"
+	// LotHIFO closes the lot that cost the most a share first.
+	LotHIFO LotMethod = "hifo"
+	// LotSpecificID closes the lots named by lot selections, and the
+	// oldest first for what no selection names.
+	LotSpecificID LotMethod = "specific"
+)
+
+var LotMethods = []LotMethod{LotFIFO, LotLIFO, LotHIFO, LotSpecificID}
+
+func ValidLotMethod(method string) bool {
+	for _, m := range LotMethods {
+		if string(m) == method {
+			return true
+		}
+	}
+	return false
+}
+
+// lotActions are the actions that open and close lots.  Dividends, interest
+// and the like move cash, not shares.
+var lotActions = map[ActionType]bool{
+	ActionBuy:         true,
+	ActionSell:        true,
+	ActionBuyToOpen:   true,
+	ActionSellToOpen:  true,
+	ActionBuyToClose:  true,
+	ActionSellToClose: true,
+	ActionReinvest:    true,
+	ActionCashInLieu:  true,
+}
+
+// Lot is what one transaction opened of a position that is still open.
+// Quantity is negative for a lot sold short, and Amount is what the open
+// quantity was bought or sold short for, signed like Transaction.Amount.
+type Lot struct {
+	Account  string
+	Symbol   string
+	OpenID   uint
+	Opened   time.Time
+	Quantity float64
+	Amount   decimal.Decimal
+}
+
+type Lots []Lot
+
+// ClosedLot is a lot, or the part of one, that a later transaction closed.
+// Cost and Proceeds are positive amounts and Gain is the realized gain.
+type ClosedLot struct {
+	Account     string
+	Symbol      string
+	OpenID      uint
+	CloseID     uint
+	Opened      time.Time
+	Closed      time.Time
+	Short       bool
+	Quantity    float64
+	Cost        decimal.Decimal
+	Proceeds    decimal.Decimal
+	Gain        decimal.Decimal
+	HoldingDays int
+	// LongTerm is a lot held for more than a year.  Short sales are always
+	// short term.
+	LongTerm bool
+}
+
+type ClosedLots []ClosedLot
+
+// LotSelection names a lot a closing transaction closes, and how much of it,
+// in accounts that pick lots by specific identification.
+type LotSelection struct {
+	CloseID  uint
+	OpenID   uint
+	Quantity float64
+}
+
+// MatchLots follows every transaction of the positions as a lot and matches
+// the closing ones against them by the method of their account, FIFO when
+// the account has none.  It returns the lots still open and the closed
+// ones with their realized gain.
+func (p Positions) MatchLots(methods map[string]LotMethod, selections []LotSelection) (Lots, ClosedLots) {
+	picks := map[uint][]LotSelection{}
+	for _, s := range selections {
+		picks[s.CloseID] = append(picks[s.CloseID], s)
+	}
+
+	open := Lots{}
+	closed := ClosedLots{}
+	for _, pos := range p {
+		method, ok := methods[pos.Account]
+		if !ok {
+			method = LotFIFO
+		}
+		o, c := matchLots(pos, method, picks)
+		open = append(open, o...)
+		closed = append(closed, c...)
+	}
+
+	sort.SliceStable(open, func(i, j int) bool {
+		if open[i].Account != open[j].Account {
+			return open[i].Account < open[j].Account
+		}
+		if open[i].Symbol != open[j].Symbol {
+			return open[i].Symbol < open[j].Symbol
+		}
+		return open[i].Opened.Before(open[j].Opened)
+	})
+	sort.SliceStable(closed, func(i, j int) bool {
+		if !closed[i].Closed.Equal(closed[j].Closed) {
+			return closed[i].Closed.Before(closed[j].Closed)
+		}
+		if closed[i].Account != closed[j].Account {
+			return closed[i].Account < closed[j].Account
+		}
+		return closed[i].Symbol < closed[j].Symbol
+	})
+	return open, closed
+}
+
+func matchLots(pos Position, method LotMethod, picks map[uint][]LotSelection) (Lots, ClosedLots) {
+	trans := make(Transactions, len(pos.Transactions))
+	copy(trans, pos.Transactions)
+	sort.SliceStable(trans, func(i, j int) bool {
+		if !trans[i].Date.Equal(trans[j].Date) {
+			return trans[i].Date.Before(trans[j].Date)
+		}
+		return trans[i].ID < trans[j].ID
+	})
+
+	lots := Lots{}
+	closed := ClosedLots{}
+	for _, tran := range trans {
+		a := tran.ActionType()
+		if tran.Quantity == 0 {
+			// a spinoff moves part of the cost of the lots to the new shares
+			if a == ActionCorporate {
+				lots.spread(tran.Amount)
+			}
+			continue
+		}
+
+		// the direction the transaction opens a lot in; assignments,
+		// expirations and exercises only close what is open
+		var direction float64
+		switch {
+		case a == ActionAssigned || a == ActionExpired || a == ActionExercised:
+		case lotActions[a] && a.Long():
+			direction = 1
+		case lotActions[a]:
+			direction = -1
+		default:
+			continue
+		}
+
+		selected := map[uint]float64{}
+		if method == LotSpecificID {
+			for _, s := range picks[tran.ID] {
+				selected[s.OpenID] += s.Quantity
+			}
+		}
+
+		remaining := tran.Quantity
+		amount := tran.Amount
+		for remaining > fractionTolerance {
+			idx, limit := lots.next(direction, method, selected)
+			if idx < 0 {
+				break
+			}
+			lot := &lots[idx]
+			quantity := math.Min(remaining, limit)
+			openPart := portion(lot.Amount, quantity, math.Abs(lot.Quantity))
+			closePart := portion(amount, quantity, remaining)
+			closed = append(closed, closeLot(*lot, tran, quantity, openPart, closePart))
+
+			if _, ok := selected[lot.OpenID]; ok {
+				selected[lot.OpenID] -= quantity
+			}
+			if lot.Quantity < 0 {
+				lot.Quantity += quantity
+			} else {
+				lot.Quantity -= quantity
+			}
+			lot.Amount = lot.Amount.Sub(openPart)
+			remaining -= quantity
+			amount = amount.Sub(closePart)
+			if math.Abs(lot.Quantity) < fractionTolerance {
+				lots = append(lots[:idx], lots[idx+1:]...)
+			}
+		}
+
+		if remaining > fractionTolerance && direction != 0 {
+			lots = append(lots, Lot{
+				Account:  tran.Account,
+				Symbol:   tran.Symbol,
+				OpenID:   tran.ID,
+				Opened:   tran.Date,
+				Quantity: direction * remaining,
+				Amount:   amount,
+			})
+		}
+	}
+	return lots, closed
+}
+
+// next is the index of the lot a transaction opening in direction closes
+// next, and how much of it may be closed, or -1 when there is none.  A
+// direction of 0 closes lots of either direction.
+func (l Lots) next(direction float64, method LotMethod, selected map[uint]float64) (int, float64) {
+	candidates := []int{}
+	for idx, lot := range l {
+		if direction == 0 || lot.Quantity*direction < 0 {
+			candidates = append(candidates, idx)
+		}
+	}
+	if len(candidates) == 0 {
+		return -1, 0
+	}
+
+	for _, idx := range candidates {
+		if left := selected[l[idx].OpenID]; left > fractionTolerance {
+			return idx, math.Min(left, math.Abs(l[idx].Quantity))
+		}
+	}
+
+	pick := candidates[0]
+	switch method {
+	case LotLIFO:
+		pick = candidates[len(candidates)-1]
+	case LotHIFO:
+		// the lowest amount a share is the highest cost of a long lot, and
+		// the lowest proceeds of a short one
+		for _, idx := range candidates[1:] {
+			if l[idx].unitAmount().LessThan(l[pick].unitAmount()) {
+				pick = idx
+			}
+		}
+	}
+	return pick, math.Abs(l[pick].Quantity)
+}
+
+func (l Lot) unitAmount() decimal.Decimal {
+	return l.Amount.Div(decimal.NewFromFloat(math.Abs(l.Quantity)))
+}
+
+// spread adds amount to the open lots in proportion to their quantity.
+func (l Lots) spread(amount decimal.Decimal) {
+	total := 0.0
+	for _, lot := range l {
+		total += math.Abs(lot.Quantity)
+	}
+	for idx := range l {
+		part := portion(amount, math.Abs(l[idx].Quantity), total)
+		l[idx].Amount = l[idx].Amount.Add(part)
+		amount = amount.Sub(part)
+		total -= math.Abs(l[idx].Quantity)
+	}
+}
+
+// portion is the part of total that quantity of whole comes to, to the
+// cent.  The whole quantity gets all of it, so the parts add up.
+func portion(total decimal.Decimal, quantity float64, whole float64) decimal.Decimal {
+	if quantity >= whole-fractionTolerance {
+		return total
+	}
+	return total.Mul(decimal.NewFromFloat(quantity / whole)).Round(2)
+}
+
+func closeLot(lot Lot, tran Transaction, quantity float64, openPart decimal.Decimal, closePart decimal.Decimal) ClosedLot {
+	c := ClosedLot{
+		Account:     lot.Account,
+		Symbol:      lot.Symbol,
+		OpenID:      lot.OpenID,
+		CloseID:     tran.ID,
+		Opened:      lot.Opened,
+		Closed:      tran.Date,
+		Short:       lot.Quantity < 0,
+		Quantity:    quantity,
+		HoldingDays: int(tran.Date.Sub(lot.Opened).Hours() / 24),
+	}
+	if c.Short {
+		c.Proceeds = openPart
+		c.Cost = closePart.Neg()
+	} else {
+		c.Cost = openPart.Neg()
+		c.Proceeds = closePart
+		c.LongTerm = tran.Date.After(lot.Opened.AddDate(1, 0, 0))
+	}
+	c.Gain = c.Proceeds.Sub(c.Cost)
+	return c
+}
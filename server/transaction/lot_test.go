@@ -0,0 +1,107 @@
+package transaction_test
+
+import (
+	"testing"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+)
+
+func lotTransactions() transaction.Transactions {
+	return transaction.Transactions{
+		{ID: 1, Account: "IRA", Symbol: "KO", Action: "Buy", Quantity: 10, Amount: dec("-500"), Date: date("01/03/2022")},
+		{ID: 2, Account: "IRA", Symbol: "KO", Action: "Buy", Quantity: 10, Amount: dec("-700"), Date: date("06/01/2022")},
+		{ID: 3, Account: "IRA", Symbol: "KO", Action: "Buy", Quantity: 10, Amount: dec("-600"), Date: date("09/01/2022")},
+		{ID: 4, Account: "IRA", Symbol: "KO", Action: "Sell", Quantity: 15, Amount: dec("975"), Date: date("03/01/2023")},
+	}
+}
+
+// closedLot is the opening transaction, quantity and gain of a closed lot.
+type closedLot struct {
+	openID   uint
+	quantity float64
+	gain     string
+}
+
+func TestMatchLots(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     transaction.LotMethod
+		selections []transaction.LotSelection
+		closed     []closedLot
+	}{
+		{
+			name:   "fifo",
+			method: transaction.LotFIFO,
+			closed: []closedLot{{1, 10, "150"}, {2, 5, "-25"}},
+		},
+		{
+			name:   "lifo",
+			method: transaction.LotLIFO,
+			closed: []closedLot{{3, 10, "50"}, {2, 5, "-25"}},
+		},
+		{
+			name:   "hifo",
+			method: transaction.LotHIFO,
+			closed: []closedLot{{2, 10, "-50"}, {3, 5, "25"}},
+		},
+		{
+			name:       "specific",
+			method:     transaction.LotSpecificID,
+			selections: []transaction.LotSelection{{CloseID: 4, OpenID: 3, Quantity: 4}},
+			closed:     []closedLot{{3, 4, "20"}, {1, 10, "150"}, {2, 1, "-5"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trans := lotTransactions()
+			positions := trans.MergeTransactions(nil, nil).CollectPositions()
+			open, closed := positions.MatchLots(map[string]transaction.LotMethod{"IRA": tt.method}, tt.selections)
+
+			if len(closed) != len(tt.closed) {
+				t.Fatalf("Expected %d closed lots, got %v", len(tt.closed), closed)
+			}
+			for idx, e := range tt.closed {
+				c := closed[idx]
+				if c.OpenID != e.openID || c.Quantity != e.quantity || !c.Gain.Equal(dec(e.gain)) {
+					t.Errorf("Expected lot %d closing %v for a gain of %s, got lot %d closing %v for %s",
+						e.openID, e.quantity, e.gain, c.OpenID, c.Quantity, c.Gain)
+				}
+			}
+			left := 0.0
+			for _, lot := range open {
+				left += lot.Quantity
+			}
+			if left != 15 {
+				t.Errorf("Expected 15 shares left open, got %v", left)
+			}
+		})
+	}
+}
+
+func TestMatchLotsHoldingPeriod(t *testing.T) {
+	trans := transaction.Transactions{
+		{ID: 1, Account: "IRA", Symbol: "KO", Action: "Buy", Quantity: 10, Amount: dec("-500"), Date: date("01/03/2022")},
+		{ID: 2, Account: "IRA", Symbol: "KO", Action: "Sell", Quantity: 5, Amount: dec("300"), Date: date("01/03/2023")},
+		{ID: 3, Account: "IRA", Symbol: "KO", Action: "Sell", Quantity: 5, Amount: dec("300"), Date: date("01/04/2023")},
+		{ID: 4, Account: "IRA", Symbol: "KO 02/17/2023 60.00 C", Action: "Sell to Open", Quantity: 1, Amount: dec("120.35"), Date: date("01/05/2023")},
+		{ID: 5, Account: "IRA", Symbol: "KO 02/17/2023 60.00 C", Action: "Expired", Quantity: 1, Date: date("02/17/2023")},
+	}
+	positions := trans.MergeTransactions(nil, nil).CollectPositions()
+	open, closed := positions.MatchLots(nil, nil)
+	if len(open) != 0 {
+		t.Errorf("Expected no open lots, got %v", open)
+	}
+	if len(closed) != 3 {
+		t.Fatalf("Expected 3 closed lots, got %v", closed)
+	}
+	if closed[0].LongTerm || closed[0].HoldingDays != 365 {
+		t.Errorf("A lot sold after a year to the day is short term, got %v", closed[0])
+	}
+	if !closed[1].LongTerm || !closed[1].Cost.Equal(dec("250")) || !closed[1].Gain.Equal(dec("50")) {
+		t.Errorf("Expected a long term gain of 50 on 250, got %v", closed[1])
+	}
+	option := closed[2]
+	if !option.Short || option.LongTerm || !option.Proceeds.Equal(dec("120.35")) || !option.Gain.Equal(dec("120.35")) {
+		t.Errorf("Expected a short term gain of 120.35 on the expired call, got %v", option)
+	}
+}
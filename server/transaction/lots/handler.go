@@ -0,0 +1,91 @@
+package lots
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/corporateaction"
+	"github.com/wazupwiddat/postrack/server/lotselection"
+	"github.com/wazupwiddat/postrack/server/split"
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/user"
+	"gorm.io/gorm"
+)
+
+// Request narrows the lots to an account and an underlying symbol when
+// they are given.
+type Request struct {
+	User    *user.User
+	Account string
+	Symbol  string
+}
+
+// Realized is the realized gain of an account, short and long term.
+type Realized struct {
+	Account   string
+	ShortTerm decimal.Decimal
+	LongTerm  decimal.Decimal
+}
+
+type Response struct {
+	Open     transaction.Lots
+	Closed   transaction.ClosedLots
+	Realized []Realized
+}
+
+// Lots matches the user's sales against the lots they close, by the lot
+// method of each account.
+func Lots(db *gorm.DB, req *Request) (*Response, error) {
+	trans, err := transaction.FindAllByUser(db, req.User)
+	if err != nil {
+		return nil, err
+	}
+	splits, err := split.FindConfirmed(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := corporateaction.FindApplied(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := accountalias.FindAllByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	selections, err := lotselection.FindAllByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	t := transaction.Transactions(trans)
+	positions := t.MergeTransactions(splits, actions).CollectPositions().Filter(func(pos transaction.Position) bool {
+		return (req.Account == "" || pos.Account == req.Account) &&
+			(req.Symbol == "" || transaction.SymbolFromOptionSymbol(pos.Symbol) == req.Symbol)
+	})
+	open, closed := positions.MatchLots(aliases.LotMethods(), selections.Selections())
+
+	realized := []Realized{}
+	byAccount := map[string]int{}
+	for _, c := range closed {
+		idx, ok := byAccount[c.Account]
+		if !ok {
+			idx = len(realized)
+			byAccount[c.Account] = idx
+			realized = append(realized, Realized{Account: c.Account})
+		}
+		if c.LongTerm {
+			realized[idx].LongTerm = realized[idx].LongTerm.Add(c.Gain)
+		} else {
+			realized[idx].ShortTerm = realized[idx].ShortTerm.Add(c.Gain)
+		}
+	}
+	sort.Slice(realized, func(i, j int) bool {
+		return realized[i].Account < realized[j].Account
+	})
+	return &Response{
+		Open:     open,
+		Closed:   closed,
+		Realized: realized,
+	}, nil
+}
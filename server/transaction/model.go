@@ -5,40 +5,66 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type Transaction struct {
 	gorm.Model
-	ID          uint   `gorm:"primary_key"`
-	UserID      uint   `gorm:"index"`
-	Account     string `gorm:"size:100"`
-	Date        string `gorm:"size:50"`
-	Action      string `gorm:"size:50"`
-	Symbol      string `gorm:"size:50"`
-	Description string `gorm:"size:250"`
-	Quantity    float64
-	Price       float64
-	FeesComm    float64
-	Amount      float64
+	ID            uint       `gorm:"primary_key"`
+	UserID        uint       `gorm:"index"`
+	ImportBatchID uint       `gorm:"index"`
+	Account       string     `gorm:"size:100"`
+	Date          time.Time  `gorm:"type:date;index"`
+	Action        string     `gorm:"size:50"`
+	Type          ActionType `gorm:"size:20;index"`
+	Symbol        string     `gorm:"size:50"`
+	Description   string     `gorm:"size:250"`
+	Quantity      float64
+	// money is exact, so totals match the broker's to the cent
+	Price       decimal.Decimal `gorm:"type:decimal(20,6)"`
+	FeesComm    decimal.Decimal `gorm:"type:decimal(20,6)"`
+	Amount      decimal.Decimal `gorm:"type:decimal(20,6)"`
+	Fingerprint string          `gorm:"size:32;index"`
+	// Option is the contract of an option transaction, and nil for stock.
+	Option *Option `gorm:"embedded;embeddedPrefix:option_"`
 
 	UniqueID string `gorm:"-:all"`
 }
 
 type TransactionFilterCond func(pos Transaction) bool
 
+// Split gives Numerator new shares for every Denominator old ones: a 2 for
+// 1 split is 2/1, a 1 for 10 reverse split is 1/10 and a 3 for 2 split is
+// 3/2.
 type Split struct {
-	Symbol string
-	Date   time.Time
-	Ratio  int
+	Symbol      string
+	Date        time.Time
+	Numerator   int
+	Denominator int
 }
 type Splits []Split
 
+// Factor is what a share quantity is multiplied by.
+func (s Split) Factor() float64 {
+	if s.Denominator == 0 {
+		return float64(s.Numerator)
+	}
+	return float64(s.Numerator) / float64(s.Denominator)
+}
+
+// WholeForward reports whether the split is a whole number for one, the only
+// kind where options get more contracts at a lower strike.  Other splits
+// leave contracts and strikes alone and change what a contract delivers.
+func (s Split) WholeForward() bool {
+	return s.Numerator > 1 && (s.Denominator == 1 || s.Denominator == 0)
+}
+
 type MergedTransactions map[string][]Transaction
 
 type Transactions []Transaction
@@ -60,18 +86,10 @@ func (a ByDate) Swap(i, j int) {
 }
 
 func (a ByDate) Less(i, j int) bool {
-	ai, err := time.Parse("01/02/2006", a[i].Date)
-	if err != nil {
-		log.Println(err)
-	}
-	aj, err := time.Parse("01/02/2006", a[j].Date)
-	if err != nil {
-		log.Println(err)
-	}
-	// if ai.Equal(aj) {
+	// if a[i].Date.Equal(a[j].Date) {
 	// 	return (a[i].Direction == "Sold" || a[i].Direction == "Bought") && (a[j].Direction == "Closed")
 	// }
-	return ai.Before(aj)
+	return a[i].Date.Before(a[j].Date)
 }
 
 type Direction int
@@ -93,7 +111,7 @@ const (
 type Position struct {
 	Account     string
 	Symbol      string
-	Amount      float64
+	Amount      decimal.Decimal
 	Direction   Direction
 	Quantity    float64
 	Disposition Disposition
@@ -114,49 +132,23 @@ func (a PostionsByDate) Swap(i, j int) {
 }
 
 func (a PostionsByDate) Less(i, j int) bool {
-	ai, err := time.Parse("01/02/2006", a[i].Transactions[0].Date)
-	if err != nil {
-		log.Println(err)
-	}
-	aj, err := time.Parse("01/02/2006", a[j].Transactions[0].Date)
-	if err != nil {
-		log.Println(err)
-	}
-	// if ai.Equal(aj) {
+	// if a[i].Transactions[0].Date.Equal(a[j].Transactions[0].Date) {
 	// 	return (a[i].Direction == "Sold" || a[i].Direction == "Bought") && (a[j].Direction == "Closed")
 	// }
-	return ai.Before(aj)
+	return a[i].Transactions[0].Date.Before(a[j].Transactions[0].Date)
 }
 
 type SumProduct struct {
-	Value float64
+	Value decimal.Decimal
 }
 
-type OptionSymbol struct {
-	Symbol     string
-	Date       string
-	Price      float64
-	OptionType string
-}
-
-func (t *Transactions) MergeTransactions() *MergedTransactions {
-	splits := []Split{}
-	splitDate, _ := time.Parse("2006-01-02", "2022-08-25")
-	splits = append(splits, Split{
-		Symbol: "TSLA",
-		Date:   splitDate,
-		Ratio:  3,
-	})
-	splitDate, _ = time.Parse("2006-01-02", "2020-08-31")
-	splits = append(splits, Split{
-		Symbol: "TSLA",
-		Date:   splitDate,
-		Ratio:  5,
-	})
-
+// MergeTransactions groups the transactions into positions by account and
+// symbol, after adjusting them for the given splits and corporate actions.
+func (t *Transactions) MergeTransactions(splits Splits, actions CorporateActions) *MergedTransactions {
 	return t.Filter(NonEmptySymbolCondition).
 		Filter(ValidActionsCondition).
-		ApplySplits(splits).
+		Adjust(splits, actions).
+		ApplyCashInLieu().
 		WithUniqueIdentifier().
 		WithMergedByUniqueID()
 }
@@ -173,13 +165,20 @@ func (t *Transactions) ApplySplits(splits Splits) *Transactions {
 			if sym != s.Symbol {
 				continue
 			}
-			td, _ := time.Parse("01/02/2006", tran.Date)
-			if td.Before(s.Date) {
-				if IsOption(tran) {
+			if tran.Date.Before(s.Date) {
+				o, isOption := tran.Contract()
+				switch {
+				case !isOption:
+					tran.Quantity = s.Factor() * tran.Quantity
+				case s.WholeForward():
 					// TSLA 09/09/2022 800.00 P -> TSLA 09/09/2022 266.67 P
-					tran.Symbol = adjustSymbolForSplit(tran.Symbol, s)
+					tran.SetContract(adjustSymbolForSplit(o, s))
+					tran.Quantity = s.Factor() * tran.Quantity
+				default:
+					// 1 contract of 100 shares -> 1 contract of 150 shares
+					o.Deliverable = o.SharesPerContract() * s.Factor()
+					tran.SetContract(o)
 				}
-				tran.Quantity = float64(s.Ratio) * tran.Quantity
 				(*t)[idx] = tran
 			}
 		}
@@ -187,6 +186,46 @@ func (t *Transactions) ApplySplits(splits Splits) *Transactions {
 	return t
 }
 
+// ApplyCashInLieu fills in the shares of "Cash In Lieu" rows that leave them
+// out.  They pay for the fraction of a share a split left, which is what
+// the split adjusted holdings of the symbol are over a whole number.
+func (t *Transactions) ApplyCashInLieu() *Transactions {
+	order := make([]int, len(*t))
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return ByDate(*t).Less(order[i], order[j])
+	})
+
+	holdings := map[string]float64{}
+	for _, idx := range order {
+		tran := (*t)[idx]
+		if IsOption(tran) {
+			continue
+		}
+		key := tran.Account + "|" + tran.Symbol
+		if tran.ActionType() == ActionCashInLieu && tran.Quantity == 0 {
+			held := holdings[key]
+			fraction := held - math.Floor(held+fractionTolerance)
+			if fraction > fractionTolerance {
+				(*t)[idx].Quantity = fraction
+				tran.Quantity = fraction
+			}
+		}
+		if getDirection(tran) == dirLong {
+			holdings[key] += tran.Quantity
+		} else {
+			holdings[key] -= tran.Quantity
+		}
+	}
+	return t
+}
+
+// fractionTolerance absorbs floating point error in split adjusted
+// quantities.
+const fractionTolerance = 0.000001
+
 func (t *Transactions) Filter(cond TransactionFilterCond) *Transactions {
 	trans := Transactions{}
 	for _, tran := range *t {
@@ -232,12 +271,13 @@ func NonEmptySymbolCondition(tran Transaction) bool {
 	return tran.Symbol != "" && strings.TrimSpace(tran.Symbol) != ""
 }
 
+// ValidActionsCondition keeps the rows that make up positions.  Splits are
+// applied from the known splits, and rows of unknown actions are left out
+// rather than guessed at.
 func ValidActionsCondition(tran Transaction) bool {
-	removeAction := []string{"Options Frwd Split", "Journal", "Stock Split"}
-	for _, ra := range removeAction {
-		if tran.Action == ra {
-			return false
-		}
+	switch tran.ActionType() {
+	case ActionUnknown, ActionSplit, ActionReverseSplit, ActionJournal:
+		return false
 	}
 	return !strings.Contains(tran.Description, "FORWARD SPLIT WITH STOCK SPLIT SHARES")
 }
@@ -286,19 +326,19 @@ func (m *MergedTransactions) CollectPositions() Positions {
 			Transactions: merged,
 			Disposition:  dispClosed,
 		}
-		amt := 0.0
+		amt := decimal.Zero
 		quant := 0.0
 		for _, t := range merged {
-			amt += t.Amount
+			amt = amt.Add(t.Amount)
 			if getDirection(t) == dirLong {
 				quant += t.Quantity
 			} else {
 				quant -= t.Quantity
 			}
-			switch t.Action {
-			case "Assigned":
+			switch t.ActionType() {
+			case ActionAssigned:
 				pos.Disposition = dispAssigned
-			case "Expired":
+			case ActionExpired:
 				pos.Disposition = dispExpired
 			}
 
@@ -317,30 +357,10 @@ func (m *MergedTransactions) CollectPositions() Positions {
 }
 
 func getDirection(t Transaction) Direction {
-	d := strings.Split(t.Action, " to ")
-	if len(d) <= 1 {
-		if d[0] == "Buy" {
-			return dirLong
-		}
-		if d[0] == "Assigned" || d[0] == "Expired" {
-			return dirLong
-		}
-		return dirShort
-	}
-	if strings.Contains(d[0], "Sell") && strings.Contains(d[1], "Open") {
-		return dirShort
-	}
-	if strings.Contains(d[0], "Buy") && strings.Contains(d[1], "Open") {
+	if t.ActionType().Long() {
 		return dirLong
 	}
-	if strings.Contains(d[0], "Sell") && strings.Contains(d[1], "Close") {
-		return dirShort
-	}
-	if strings.Contains(d[0], "Buy") && strings.Contains(d[1], "Close") {
-		return dirLong
-	}
-
-	return dirLong
+	return dirShort
 }
 
 func (p Positions) Filter(cond PositionFilterCond) Positions {
@@ -369,11 +389,11 @@ func (p Positions) SumProduct(summer PositionSummerFunc, cond ...PositionFilterC
 }
 
 func PositionSummerAmount(pos Position, sum *SumProduct) {
-	sum.Value = sum.Value + pos.Amount
+	sum.Value = sum.Value.Add(pos.Amount)
 }
 
 func PositionSummerQuantity(pos Position, sum *SumProduct) {
-	sum.Value = sum.Value + pos.Quantity
+	sum.Value = sum.Value.Add(decimal.NewFromFloat(pos.Quantity))
 }
 
 func checkCondition(cond []PositionFilterCond, pos Position) bool {
@@ -430,51 +450,3 @@ func (p Positions) UniqueSymbols(cond ...PositionFilterCond) []string {
 	}
 	return result
 }
-
-func IsOption(tran Transaction) bool {
-	d := strings.Split(tran.Symbol, " ")
-	if len(d) <= 3 {
-		return false
-	}
-	return true
-}
-
-func adjustSymbolForSplit(symbol string, s Split) string {
-	d := strings.Split(symbol, " ")
-	if len(d) <= 2 {
-		return symbol
-	}
-	strike, err := strconv.ParseFloat(d[2], 64)
-	if err != nil {
-		return symbol
-	}
-	newStrike := strike / float64(s.Ratio)
-	d[2] = fmt.Sprintf("%.2f", newStrike)
-	return strings.Join(d, " ")
-}
-
-func SymbolFromOptionSymbol(sym string) string {
-	d := strings.Split(sym, " ")
-	if len(d) <= 1 {
-		return sym
-	}
-	return d[0]
-}
-
-func ParseOptionSymbol(sym string) *OptionSymbol {
-	d := strings.Split(sym, " ")
-	if len(d) != 4 {
-		return nil
-	}
-	strike, err := strconv.ParseFloat(d[2], 64)
-	if err != nil {
-		return nil
-	}
-	os := OptionSymbol{
-		Symbol:     d[0],
-		Date:       d[1],
-		Price:      strike,
-		OptionType: d[3],
-	}
-	return &os
-}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"reflect"
 	"sort"
@@ -11,15 +12,26 @@ import (
 	"time"
 
 	"github.com/piquette/finance-go/quote"
+	"github.com/shopspring/decimal"
 	"github.com/wazupwiddat/postrack/server/transaction"
 )
 
+func date(value string) time.Time {
+	d, _ := time.Parse("01/02/2006", value)
+	return d
+}
+
+var teslaSplits = transaction.Splits{
+	{Symbol: "TSLA", Date: time.Date(2022, 8, 25, 0, 0, 0, 0, time.UTC), Numerator: 3, Denominator: 1},
+	{Symbol: "TSLA", Date: time.Date(2020, 8, 31, 0, 0, 0, 0, time.UTC), Numerator: 5, Denominator: 1},
+}
+
 func TestWithNonEmptySymbol(t *testing.T) {
 	trans := &transaction.Transactions{
-		transaction.Transaction{Symbol: "AAPL", Price: 100},
-		transaction.Transaction{Symbol: "", Price: 200},
-		transaction.Transaction{Symbol: "GOOG", Price: 300},
-		transaction.Transaction{Symbol: " ", Price: 200},
+		transaction.Transaction{Symbol: "AAPL", Price: dec("100")},
+		transaction.Transaction{Symbol: "", Price: dec("200")},
+		transaction.Transaction{Symbol: "GOOG", Price: dec("300")},
+		transaction.Transaction{Symbol: " ", Price: dec("200")},
 	}
 	merged := *trans.Filter(transaction.NonEmptySymbolCondition)
 	if len(merged) != 2 {
@@ -48,18 +60,46 @@ func TestWithUniqueIdentifier(t *testing.T) {
 	}
 }
 
+func TestWithFingerprints(t *testing.T) {
+	transactions := transaction.Transactions{
+		transaction.Transaction{Account: "IRA", Date: date("12/01/2022"), Action: "Buy", Symbol: "AAPL", Quantity: 10, Price: dec("100"), Amount: dec("-1000")},
+		transaction.Transaction{Account: "IRA", Date: date("12/01/2022"), Action: "Buy", Symbol: "AAPL", Quantity: 10, Price: dec("100"), Amount: dec("-1000")},
+		transaction.Transaction{Account: "IRA", Date: date("12/01/2022"), Action: "Buy", Symbol: "AAPL", Quantity: 5, Price: dec("100"), Amount: dec("-500")},
+	}
+	fingerprinted := *transactions.WithFingerprints()
+	if fingerprinted[0].Fingerprint == fingerprinted[1].Fingerprint {
+		t.Errorf("Expected identical rows to have different fingerprints")
+	}
+	if fingerprinted[0].Fingerprint != transaction.Fingerprint(transactions[0], 0) {
+		t.Errorf("Expected first row to be occurrence 0")
+	}
+	if fingerprinted[1].Fingerprint != transaction.Fingerprint(transactions[1], 1) {
+		t.Errorf("Expected second identical row to be occurrence 1")
+	}
+	if fingerprinted[2].Fingerprint != transaction.Fingerprint(transactions[2], 0) {
+		t.Errorf("Expected different row to be occurrence 0")
+	}
+
+	again := *transactions.WithFingerprints()
+	for i := range again {
+		if again[i].Fingerprint != fingerprinted[i].Fingerprint {
+			t.Errorf("Expected fingerprints to be stable, got %s and %s", again[i].Fingerprint, fingerprinted[i].Fingerprint)
+		}
+	}
+}
+
 func TestWithMergedByUniqueID(t *testing.T) {
 	transactions := transaction.Transactions{
-		transaction.Transaction{UniqueID: "1", Amount: 10},
-		transaction.Transaction{UniqueID: "2", Amount: 20},
-		transaction.Transaction{UniqueID: "1", Amount: 30},
+		transaction.Transaction{UniqueID: "1", Amount: dec("10")},
+		transaction.Transaction{UniqueID: "2", Amount: dec("20")},
+		transaction.Transaction{UniqueID: "1", Amount: dec("30")},
 	}
 	summedTransactions := transactions.WithMergedByUniqueID()
 	if len(*summedTransactions) != 2 {
 		t.Errorf("Expected 2 transactions, got %d", len(*summedTransactions))
 	}
-	if (*summedTransactions)["1"][0].Amount+(*summedTransactions)["1"][1].Amount != 40 {
-		t.Errorf("Expected summed amount of 40, got %f", (*summedTransactions)["1"][0].Amount+(*summedTransactions)["1"][1].Amount)
+	if sum := (*summedTransactions)["1"][0].Amount.Add((*summedTransactions)["1"][1].Amount); !sum.Equal(dec("40")) {
+		t.Errorf("Expected summed amount of 40, got %s", sum)
 	}
 }
 
@@ -81,7 +121,7 @@ func TestAssignedTransactions(t *testing.T) {
 		return pos.Account == acct &&
 			transaction.SymbolFromOptionSymbol(pos.Symbol) == sym
 	})
-	mergedTransactions := trans.MergeTransactions()
+	mergedTransactions := trans.MergeTransactions(teslaSplits, nil)
 	positions := mergedTransactions.CollectPositions()
 
 	// Sum all premiums (of the transactions we have)
@@ -96,14 +136,14 @@ func TestAssignedTransactions(t *testing.T) {
 			return false
 		}
 		for _, assignedPos := range assignedPositions {
-			ps := transaction.ParseOptionSymbol(assignedPos.Symbol)
-			if ps == nil {
+			o, ok := transaction.ParseOption(assignedPos.Symbol)
+			if !ok {
 				continue
 			}
-			match := trans.Symbol == ps.Symbol &&
-				trans.Date == ps.Date &&
-				trans.Price == ps.Price &&
-				((trans.Action == "Sell" && ps.OptionType == "C") || (trans.Action == "Buy" && ps.OptionType == "P"))
+			match := trans.Symbol == o.Underlying &&
+				trans.Date.Equal(o.Expiry) &&
+				trans.Price.Equal(o.Strike) &&
+				((trans.ActionType() == transaction.ActionSell && o.PutCall == "C") || (trans.ActionType() == transaction.ActionBuy && o.PutCall == "P"))
 			if match {
 				return true
 			}
@@ -112,16 +152,16 @@ func TestAssignedTransactions(t *testing.T) {
 	})
 
 	// Sum cost basis and quantity
-	assignedCollectedPositions := assignedTransactions.MergeTransactions().CollectPositions()
+	assignedCollectedPositions := assignedTransactions.MergeTransactions(teslaSplits, nil).CollectPositions()
 	costBasis := assignedCollectedPositions.SumProduct(transaction.PositionSummerAmount)
 	quant := assignedPositions.SumProduct(func(pos transaction.Position, sum *transaction.SumProduct) {
 		for _, tran := range pos.Transactions {
-			if tran.Action == "Assigned" {
-				sp := transaction.ParseOptionSymbol(tran.Symbol)
-				if sp.OptionType == "C" {
-					sum.Value = sum.Value - (tran.Quantity * 100)
+			if tran.ActionType() == transaction.ActionAssigned {
+				o, _ := transaction.ParseOption(tran.Symbol)
+				if o.PutCall == "C" {
+					sum.Value = sum.Value.Sub(decimal.NewFromFloat(tran.Quantity * 100))
 				} else {
-					sum.Value = sum.Value + (tran.Quantity * 100)
+					sum.Value = sum.Value.Add(decimal.NewFromFloat(tran.Quantity * 100))
 				}
 
 			}
@@ -141,10 +181,10 @@ func TestAssignedTransactions(t *testing.T) {
 	if len(assignedCollectedPositions) != 1 {
 		t.Errorf("assigned transactions should be 1")
 	}
-	if costBasis[acct].Value != -60001.509999999995 {
-		t.Errorf("cost basis should be 60001.59")
+	if !costBasis[acct].Value.Equal(dec("-60001.51")) {
+		t.Errorf("cost basis should be -60001.51, got %s", costBasis[acct].Value)
 	}
-	if quant[acct].Value != 2000 {
+	if !quant[acct].Value.Equal(dec("2000")) {
 		t.Errorf("quantity should be 2000")
 	}
 }
@@ -161,7 +201,7 @@ func TestCollectPositionFromFileAll(t *testing.T) {
 	var transactions transaction.Transactions
 	json.Unmarshal(b, &transactions)
 
-	mergedTransactions := transactions.MergeTransactions()
+	mergedTransactions := transactions.MergeTransactions(teslaSplits, nil)
 	positions := mergedTransactions.CollectPositions()
 	if len(positions) != 1 {
 		t.Errorf("Expected 1 positions transactions, but got %d", len(positions))
@@ -181,7 +221,7 @@ func TestCollectPositionFromFileAll(t *testing.T) {
 			return pos.Symbol == "SQ" // this should just be the underlying position quantity
 		},
 	)
-	if quantity["IRA"].Value != -121 {
+	if !quantity["IRA"].Value.Equal(dec("-121")) {
 		t.Errorf("Error summing quantity")
 	}
 }
@@ -198,7 +238,7 @@ func TestCollectPositionFromFileShortClosed(t *testing.T) {
 	var transactions transaction.Transactions
 	json.Unmarshal(b, &transactions)
 
-	mergedTransactions := transactions.MergeTransactions()
+	mergedTransactions := transactions.MergeTransactions(teslaSplits, nil)
 	positions := mergedTransactions.CollectPositions()
 	if len(positions) != 1 {
 		t.Errorf("Expected 1 positions transactions, but got %d", len(positions))
@@ -223,7 +263,7 @@ func TestCollectPositionFromFileShortExpired(t *testing.T) {
 	var transactions transaction.Transactions
 	json.Unmarshal(b, &transactions)
 
-	mergedTransactions := transactions.MergeTransactions()
+	mergedTransactions := transactions.MergeTransactions(teslaSplits, nil)
 	positions := mergedTransactions.CollectPositions()
 	if len(positions) != 1 {
 		t.Errorf("Expected 1 positions transactions, but got %d", len(positions))
@@ -248,7 +288,7 @@ func TestCollectPositionFromFileShortAssignedNotWorking(t *testing.T) {
 	var transactions transaction.Transactions
 	json.Unmarshal(b, &transactions)
 
-	mergedTransactions := transactions.MergeTransactions()
+	mergedTransactions := transactions.MergeTransactions(teslaSplits, nil)
 	positions := mergedTransactions.CollectPositions()
 	if len(positions) != 1 {
 		t.Errorf("Expected 1 positions transactions, but got %d", len(positions))
@@ -274,7 +314,7 @@ func TestCollectPositionFromFileShortAssigned(t *testing.T) {
 	var transactions transaction.Transactions
 	json.Unmarshal(b, &transactions)
 
-	mergedTransactions := transactions.MergeTransactions()
+	mergedTransactions := transactions.MergeTransactions(teslaSplits, nil)
 	positions := mergedTransactions.CollectPositions()
 	if len(positions) != 1 {
 		t.Errorf("Expected 1 positions transactions, but got %d", len(positions))
@@ -300,7 +340,7 @@ func TestCollectPositionFromFileOpened(t *testing.T) {
 	var transactions transaction.Transactions
 	json.Unmarshal(b, &transactions)
 
-	mergedTransactions := transactions.MergeTransactions()
+	mergedTransactions := transactions.MergeTransactions(teslaSplits, nil)
 	positions := mergedTransactions.CollectPositions()
 	if len(positions) != 1 {
 		t.Errorf("Expected 1 positions transactions, but got %d", len(positions))
@@ -320,19 +360,21 @@ func TestCollectPositionFromFileOpened(t *testing.T) {
 			return pos.Symbol == "SQ" // this should just be the underlying position quantity
 		},
 	)
-	if quantity["IRA"].Value != 1279 {
-		t.Errorf("Error summing quantity, %f", quantity["IRA"].Value)
+	if !quantity["IRA"].Value.Equal(dec("1279")) {
+		t.Errorf("Error summing quantity, %s", quantity["IRA"].Value)
 	}
 }
 
 func TestCollectPositions(t *testing.T) {
 	transactions := transaction.Transactions{
-		transaction.Transaction{Account: "A", Symbol: "S1", Action: "Buy", Quantity: 16, Amount: -10.0, Date: "12/01/2022"},
-		transaction.Transaction{Account: "A", Symbol: "S1", Action: "Sell", Quantity: 16, Amount: 20.0, Date: "12/02/2022"},
-		transaction.Transaction{Account: "B", Symbol: "S1", Quantity: 16, Amount: 5.0, Date: "12/03/2022"},
-		transaction.Transaction{Account: "B", Symbol: "S2", Quantity: 16, Amount: 15.0, Date: "12/04/2022"},
-	}
-	mergedTransactions := transactions.MergeTransactions()
+		transaction.Transaction{Account: "A", Symbol: "S1", Action: "Buy", Quantity: 16, Amount: dec("-10.0"), Date: date("12/01/2022")},
+		transaction.Transaction{Account: "A", Symbol: "S1", Action: "Sell", Quantity: 16, Amount: dec("20.0"), Date: date("12/02/2022")},
+		transaction.Transaction{Account: "B", Symbol: "S1", Action: "Buy", Quantity: 16, Amount: dec("5.0"), Date: date("12/03/2022")},
+		transaction.Transaction{Account: "B", Symbol: "S2", Action: "Buy", Quantity: 16, Amount: dec("15.0"), Date: date("12/04/2022")},
+		// rows of unknown actions are not guessed at
+		transaction.Transaction{Account: "B", Symbol: "S2", Action: "Mystery", Quantity: 16, Amount: dec("99.0"), Date: date("12/05/2022")},
+	}
+	mergedTransactions := transactions.MergeTransactions(teslaSplits, nil)
 	positions := mergedTransactions.CollectPositions()
 
 	if len(positions) != 3 {
@@ -341,51 +383,138 @@ func TestCollectPositions(t *testing.T) {
 
 	for _, s := range positions {
 		if s.Symbol == "S1" && s.Account == "A" {
-			if s.Amount != 10.0 {
-				t.Errorf("Expected a position amount of 10.0 for Account A and Symbol S1, but got %f", s.Amount)
+			if !s.Amount.Equal(dec("10")) {
+				t.Errorf("Expected a position amount of 10.0 for Account A and Symbol S1, but got %s", s.Amount)
 			}
 			if s.Quantity != 0 {
 				t.Errorf("Expected a position quantity of 0 for Account A and Symbol S1, but got %f", s.Quantity)
 			}
 		} else if s.Symbol == "S1" && s.Account == "B" {
-			if s.Amount != 5.0 {
-				t.Errorf("Expected a position amount of 5.0 for Account B and Symbol S1, but got %f", s.Amount)
+			if !s.Amount.Equal(dec("5")) {
+				t.Errorf("Expected a position amount of 5.0 for Account B and Symbol S1, but got %s", s.Amount)
 			}
 		} else if s.Symbol == "S2" && s.Account == "B" {
-			if s.Amount != 15.0 {
-				t.Errorf("Expected a position amount of 15.0 for Account B and Symbol S2, but got %f", s.Amount)
+			if !s.Amount.Equal(dec("15")) {
+				t.Errorf("Expected a position amount of 15.0 for Account B and Symbol S2, but got %s", s.Amount)
 			}
 		}
 	}
 }
 
+type splitRow struct {
+	symbol   string
+	quantity float64
+	shares   float64
+}
+
 func TestApplySplits(t *testing.T) {
-	// given
-	transactions := transaction.Transactions{
-		{Symbol: "TSLA", Date: "08/09/2022", Quantity: 100},
-		{Symbol: "AAPL", Date: "09/09/2022", Quantity: 200},
-		{Symbol: "TSLA 09/10/2022 800.00 P", Date: "08/09/2022", Quantity: 10},
-	}
-	splits := transaction.Splits{
-		{Symbol: "TSLA", Date: time.Date(2022, 9, 9, 0, 0, 0, 0, time.Local), Ratio: 3},
+	splitDate := time.Date(2022, 9, 9, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		split transaction.Split
+		given transaction.Transactions
+		// symbol, quantity and shares per contract of each row
+		expected []splitRow
+	}{
+		{
+			name:  "forward",
+			split: transaction.Split{Symbol: "TSLA", Date: splitDate, Numerator: 3, Denominator: 1},
+			given: transaction.Transactions{
+				{Symbol: "TSLA", Date: date("08/09/2022"), Quantity: 100},
+				{Symbol: "AAPL", Date: date("09/09/2022"), Quantity: 200},
+				{Symbol: "TSLA 09/10/2022 800.00 P", Date: date("08/09/2022"), Quantity: 10},
+			},
+			expected: []splitRow{
+				{"TSLA", 300, 100},
+				{"AAPL", 200, 100},
+				{"TSLA 09/10/2022 266.67 P", 30, 100},
+			},
+		},
+		{
+			name:  "reverse",
+			split: transaction.Split{Symbol: "GE", Date: splitDate, Numerator: 1, Denominator: 8},
+			given: transaction.Transactions{
+				{Symbol: "GE", Date: date("08/09/2022"), Quantity: 800},
+				{Symbol: "GE", Date: date("09/12/2022"), Quantity: 10},
+				{Symbol: "GE 10/21/2022 10.00 C", Date: date("08/09/2022"), Quantity: 2},
+			},
+			expected: []splitRow{
+				{"GE", 100, 100},
+				{"GE", 10, 100},
+				{"GE 10/21/2022 10.00 C", 2, 12.5},
+			},
+		},
+		{
+			name:  "fractional",
+			split: transaction.Split{Symbol: "KO", Date: splitDate, Numerator: 3, Denominator: 2},
+			given: transaction.Transactions{
+				{Symbol: "KO", Date: date("08/09/2022"), Quantity: 25},
+				{Symbol: "KO 10/21/2022 60.00 P", Date: date("08/09/2022"), Quantity: 1},
+			},
+			expected: []splitRow{
+				{"KO", 37.5, 100},
+				{"KO 10/21/2022 60.00 P", 1, 150},
+			},
+		},
 	}
-	expected := transaction.Transactions{
-		{Symbol: "TSLA", Date: "08/09/2022", Quantity: 300},
-		{Symbol: "AAPL", Date: "09/09/2022", Quantity: 200},
-		{Symbol: "TSLA 09/10/2022 266.67 P", Date: "08/09/2022", Quantity: 30},
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := *tt.given.ApplySplits(transaction.Splits{tt.split})
+			if len(result) != len(tt.expected) {
+				t.Fatalf("Expected %d rows but got %d", len(tt.expected), len(result))
+			}
+			for idx, e := range tt.expected {
+				got := splitRow{result[idx].Symbol, result[idx].Quantity, result[idx].SharesPerContract()}
+				if got != e {
+					t.Errorf("Expected %v but got %v", e, got)
+				}
+			}
+		})
 	}
+}
 
-	// when
-	result := transactions.ApplySplits(splits)
-
-	// then
-	if len(*result) != len(expected) {
-		t.Errorf("Expected length %d but got %d", len(expected), len(*result))
+func TestApplyCashInLieu(t *testing.T) {
+	tests := []struct {
+		name     string
+		given    transaction.Transactions
+		expected float64
+	}{
+		{
+			name: "fraction left by a split",
+			given: transaction.Transactions{
+				{Account: "A", Symbol: "GE", Date: date("08/09/2022"), Action: "Buy", Quantity: 12.625},
+				{Account: "A", Symbol: "GE", Date: date("09/12/2022"), Action: "Cash In Lieu", Amount: dec("45.10")},
+			},
+			expected: 0.625,
+		},
+		{
+			name: "quantity given by the broker",
+			given: transaction.Transactions{
+				{Account: "A", Symbol: "KO", Date: date("08/09/2022"), Action: "Buy", Quantity: 37.5},
+				{Account: "A", Symbol: "KO", Date: date("09/12/2022"), Action: "Cash In Lieu", Quantity: 0.5, Amount: dec("30.25")},
+			},
+			expected: 0.5,
+		},
+		{
+			name: "whole holdings",
+			given: transaction.Transactions{
+				{Account: "A", Symbol: "GE", Date: date("08/09/2022"), Action: "Buy", Quantity: 100},
+				{Account: "A", Symbol: "GE", Date: date("09/12/2022"), Action: "Cash In Lieu", Amount: dec("1.10")},
+			},
+			expected: 0,
+		},
 	}
-	for i, tran := range *result {
-		if tran.Symbol != expected[i].Symbol || tran.Date != expected[i].Date || tran.Quantity != expected[i].Quantity {
-			t.Errorf("Expected %v but got %v", expected[i], tran)
-		}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := *tt.given.ApplyCashInLieu()
+			if got := result[1].Quantity; math.Abs(got-tt.expected) > 0.000001 {
+				t.Errorf("Expected cash in lieu of %v shares but got %v", tt.expected, got)
+			}
+			positions := tt.given.MergeTransactions(nil, nil).CollectPositions()
+			if len(positions) != 1 || positions[0].Quantity != math.Floor(positions[0].Quantity) {
+				t.Errorf("Expected whole shares left but got %v", positions)
+			}
+		})
 	}
 }
 
@@ -404,10 +533,10 @@ func TestUniqueAccounts(t *testing.T) {
 
 func TestSumProduct(t *testing.T) {
 	positions := transaction.Positions{
-		{Account: "A", Symbol: "APPL", Amount: 100.0},
-		{Account: "A", Symbol: "GOOG", Amount: 200.0},
-		{Account: "B", Symbol: "APPL", Amount: 300.0},
-		{Account: "B", Symbol: "GOOG", Amount: 400.0},
+		{Account: "A", Symbol: "APPL", Amount: dec("100.0")},
+		{Account: "A", Symbol: "GOOG", Amount: dec("200.0")},
+		{Account: "B", Symbol: "APPL", Amount: dec("300.0")},
+		{Account: "B", Symbol: "GOOG", Amount: dec("400.0")},
 	}
 
 	cond := func(pos transaction.Position) bool {
@@ -416,11 +545,11 @@ func TestSumProduct(t *testing.T) {
 
 	result := positions.SumProduct(transaction.PositionSummerAmount, cond)
 	expectedResult := map[string]transaction.SumProduct{
-		"A": {Value: 100.0},
-		"B": {Value: 300.0},
+		"A": {Value: dec("100")},
+		"B": {Value: dec("300")},
 	}
 
-	if !reflect.DeepEqual(result, expectedResult) {
+	if !sumsEqual(result, expectedResult) {
 		t.Errorf("Expected result to be %v but got %v", expectedResult, result)
 	}
 
@@ -432,14 +561,58 @@ func TestSumProduct(t *testing.T) {
 	}
 	result1 := positions.SumProduct(transaction.PositionSummerAmount, cond1, cond2)
 	expectedResult1 := map[string]transaction.SumProduct{
-		"B": {Value: 300.0},
+		"B": {Value: dec("300")},
 	}
 
-	if !reflect.DeepEqual(result1, expectedResult1) {
+	if !sumsEqual(result1, expectedResult1) {
 		t.Errorf("Expected result to be %v but got %v", expectedResult1, result1)
 	}
 }
 
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		action   string
+		expected transaction.ActionType
+		long     bool
+	}{
+		{"Buy", transaction.ActionBuy, true},
+		{"Sell to Open", transaction.ActionSellToOpen, false},
+		{"BUY TO CLOSE", transaction.ActionBuyToClose, true},
+		{"Assigned", transaction.ActionAssigned, true},
+		{"Exchange or Exercise", transaction.ActionExercised, false},
+		{"Qualified Dividend", transaction.ActionDividend, false},
+		{"Reinvest Shares", transaction.ActionReinvest, true},
+		{"Reverse Split", transaction.ActionReverseSplit, false},
+		{"Cash In Lieu", transaction.ActionCashInLieu, false},
+	}
+	for _, tt := range tests {
+		a, err := transaction.ParseAction(tt.action)
+		if err != nil {
+			t.Errorf("%s: %s", tt.action, err)
+			continue
+		}
+		if a != tt.expected || a.Long() != tt.long {
+			t.Errorf("%s: expected %s (long %t), got %s (long %t)", tt.action, tt.expected, tt.long, a, a.Long())
+		}
+	}
+
+	if _, err := transaction.ParseAction("Mystery"); err == nil {
+		t.Errorf("Expected an unknown action error")
+	}
+}
+
+func sumsEqual(a map[string]transaction.SumProduct, b map[string]transaction.SumProduct) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if !v.Value.Equal(b[k].Value) {
+			return false
+		}
+	}
+	return true
+}
+
 func TestQuote(t *testing.T) {
 	q, err := quote.Get("AAPL")
 	if err != nil {
@@ -450,3 +623,22 @@ func TestQuote(t *testing.T) {
 	// Success!
 	fmt.Println(q)
 }
+
+func TestParseDate(t *testing.T) {
+	d, err := transaction.ParseDate("02/29/2024")
+	if err != nil || !d.Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 2024-02-29 but got %v %v", d, err)
+	}
+	if _, err := transaction.ParseDate("2024-02-29"); err == nil {
+		t.Errorf("Expected an error for a date in the wrong layout")
+	}
+
+	local := time.Date(2024, 2, 29, 23, 30, 0, 0, time.FixedZone("EST", -5*3600))
+	if day := transaction.Day(local); !day.Equal(d) {
+		t.Errorf("Expected the calendar day to be kept but got %v", day)
+	}
+}
+
+func dec(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
@@ -0,0 +1,247 @@
+package transaction
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// StandardDeliverable is the shares one option contract delivers.
+const StandardDeliverable = 100
+
+// StandardMultiplier is what an option's premium is multiplied by for one
+// contract.
+const StandardMultiplier = 100
+
+// miniSize is the shares, and the multiplier, of a mini option.
+const miniSize = 10
+
+// Option is an option contract.  Root is the symbol it trades under, which
+// is the underlying for standard contracts.  Minis and contracts adjusted for
+// a corporate action trade under the underlying with a digit added, e.g.
+// AAPL7 or TSLA1.
+type Option struct {
+	Root       string          `gorm:"size:20"`
+	Underlying string          `gorm:"size:20"`
+	Expiry     time.Time       `gorm:"type:date"`
+	Strike     decimal.Decimal `gorm:"type:decimal(20,6)"`
+	PutCall    string          `gorm:"size:1"`
+	Multiplier float64
+	// Deliverable is the shares one contract delivers; 0 means the
+	// standard 100.
+	Deliverable float64
+}
+
+// weeklyRoots are the roots of index options that trade under a symbol of
+// their own.
+var weeklyRoots = map[string]string{
+	"SPXW": "SPX",
+	"NDXP": "NDX",
+	"RUTW": "RUT",
+	"VIXW": "VIX",
+}
+
+var (
+	// AAPL  240119C00190000, the root padded to six characters or not
+	occOptionRegex = regexp.MustCompile(`^([A-Z][A-Z0-9.]{0,5}) *(\d{6})([CP])(\d{8})$`)
+	// -AAPL240119C190, -SPY240119P472.5
+	dashedOptionRegex = regexp.MustCompile(`^-([A-Z][A-Z0-9.]*?)(\d{6})([CP])(\d+(?:\.\d+)?)$`)
+)
+
+// ParseOption reads an option symbol in the Schwab layout
+// AAPL 01/19/2024 190.00 C, the OCC layout AAPL  240119C00190000, the
+// Fidelity layout -AAPL240119C190 or the IBKR description AAPL 19JAN24 190 C.
+func ParseOption(symbol string) (Option, bool) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if d := strings.Fields(symbol); len(d) == 4 {
+		return parseSpacedOption(d)
+	}
+	if m := occOptionRegex.FindStringSubmatch(symbol); m != nil {
+		strike, err := decimal.NewFromString(m[4])
+		if err != nil {
+			return Option{}, false
+		}
+		return newOption(m[1], m[2], strike.Shift(-3), m[3])
+	}
+	if m := dashedOptionRegex.FindStringSubmatch(symbol); m != nil {
+		strike, err := decimal.NewFromString(m[4])
+		if err != nil {
+			return Option{}, false
+		}
+		return newOption(m[1], m[2], strike, m[3])
+	}
+	return Option{}, false
+}
+
+func parseSpacedOption(d []string) (Option, bool) {
+	if d[3] != "C" && d[3] != "P" {
+		return Option{}, false
+	}
+	strike, err := decimal.NewFromString(d[2])
+	if err != nil {
+		return Option{}, false
+	}
+	for _, layout := range []string{DateLayout, "02Jan06"} {
+		expiry, err := time.Parse(layout, d[1])
+		if err != nil {
+			continue
+		}
+		o := optionOfRoot(d[0])
+		o.Expiry = expiry
+		o.Strike = strike
+		o.PutCall = d[3]
+		return o, true
+	}
+	return Option{}, false
+}
+
+func newOption(root string, yymmdd string, strike decimal.Decimal, putCall string) (Option, bool) {
+	expiry, err := time.Parse("060102", yymmdd)
+	if err != nil {
+		return Option{}, false
+	}
+	o := optionOfRoot(root)
+	o.Expiry = expiry
+	o.Strike = strike
+	o.PutCall = putCall
+	return o, true
+}
+
+// optionOfRoot tells the underlying and size of a contract from the symbol
+// it trades under.
+func optionOfRoot(root string) Option {
+	o := Option{Root: root, Underlying: root, Multiplier: StandardMultiplier}
+	if underlying, ok := weeklyRoots[root]; ok {
+		o.Underlying = underlying
+		return o
+	}
+	underlying := strings.TrimRight(root, "0123456789")
+	if underlying == "" || underlying == root {
+		return o
+	}
+	o.Underlying = underlying
+	if strings.HasSuffix(root, "7") {
+		o.Multiplier = miniSize
+		o.Deliverable = miniSize
+	}
+	return o
+}
+
+// Symbol is the contract in the Schwab layout AAPL 01/19/2024 190.00 C,
+// which is how transactions name it.
+func (o Option) Symbol() string {
+	return fmt.Sprintf("%s %s %s %s", o.Root, o.Expiry.Format(DateLayout), o.Strike.StringFixed(2), o.PutCall)
+}
+
+// SharesPerContract is the shares one contract delivers.
+func (o Option) SharesPerContract() float64 {
+	if o.Deliverable == 0 {
+		return StandardDeliverable
+	}
+	return o.Deliverable
+}
+
+// Contract is the option the transaction trades.  Rows stored before
+// contracts were are read from their symbol.
+func (t Transaction) Contract() (Option, bool) {
+	if t.Option != nil {
+		return *t.Option, true
+	}
+	return ParseOption(t.Symbol)
+}
+
+// SetContract makes the transaction trade o.
+func (t *Transaction) SetContract(o Option) {
+	t.Symbol = o.Symbol()
+	t.Option = &o
+}
+
+// SharesPerContract is the shares one contract of an option delivers.
+func (t Transaction) SharesPerContract() float64 {
+	o, ok := t.Contract()
+	if !ok {
+		return StandardDeliverable
+	}
+	return o.SharesPerContract()
+}
+
+func IsOption(tran Transaction) bool {
+	_, ok := tran.Contract()
+	return ok
+}
+
+// SymbolFromOptionSymbol is the underlying of an option symbol, or the
+// symbol itself for a stock.
+func SymbolFromOptionSymbol(sym string) string {
+	if o, ok := ParseOption(sym); ok {
+		return o.Underlying
+	}
+	d := strings.Split(sym, " ")
+	return d[0]
+}
+
+// OCCToSymbol converts an OCC option symbol (the root padded to six
+// characters, YYMMDD, C or P, and the strike times 1000 in eight digits)
+// into the option symbol used here, e.g. AAPL 01/19/2024 190.00 C.
+func OCCToSymbol(occ string) (string, bool) {
+	if !occOptionRegex.MatchString(occ) {
+		return "", false
+	}
+	o, ok := ParseOption(occ)
+	if !ok {
+		return "", false
+	}
+	return o.Symbol(), true
+}
+
+// adjustSymbolForSplit divides the strike of an option symbol by the split.
+func adjustSymbolForSplit(o Option, s Split) Option {
+	o.Strike = o.Strike.Div(decimal.NewFromFloat(s.Factor())).Round(2)
+	return o
+}
+
+// renameSymbol replaces the underlying of a stock or option symbol.
+func renameSymbol(tran *Transaction, newSymbol string) {
+	o, ok := tran.Contract()
+	if !ok {
+		tran.Symbol = newSymbol
+		return
+	}
+	o.Root = newSymbol + strings.TrimPrefix(o.Root, o.Underlying)
+	o.Underlying = newSymbol
+	tran.SetContract(o)
+}
+
+// MigrateOptions stores the contract of the option transactions stored
+// before contracts were.
+func MigrateOptions(db *gorm.DB) error {
+	var trans []Transaction
+	err := db.Where("(option_root IS NULL OR option_root = ?) AND symbol LIKE ?", "", "% % % %").Find(&trans).Error
+	if err != nil {
+		return err
+	}
+	for _, tran := range trans {
+		o, ok := ParseOption(tran.Symbol)
+		if !ok {
+			continue
+		}
+		o.Expiry = StorageDay(o.Expiry)
+		err = db.Table("transactions").Where("id = ?", tran.ID).Updates(map[string]interface{}{
+			"option_root":        o.Root,
+			"option_underlying":  o.Underlying,
+			"option_expiry":      o.Expiry,
+			"option_strike":      o.Strike,
+			"option_put_call":    o.PutCall,
+			"option_multiplier":  o.Multiplier,
+			"option_deliverable": o.Deliverable,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
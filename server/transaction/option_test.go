@@ -0,0 +1,58 @@
+package transaction_test
+
+import (
+	"testing"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+)
+
+func TestParseOption(t *testing.T) {
+	tests := []struct {
+		symbol     string
+		expected   string
+		underlying string
+		shares     float64
+		multiplier float64
+	}{
+		{"AAPL 01/19/2024 190.00 C", "AAPL 01/19/2024 190.00 C", "AAPL", 100, 100},
+		{"AAPL  240119C00190000", "AAPL 01/19/2024 190.00 C", "AAPL", 100, 100},
+		{"SPY240119P00472500", "SPY 01/19/2024 472.50 P", "SPY", 100, 100},
+		{"-SPY240119P472.5", "SPY 01/19/2024 472.50 P", "SPY", 100, 100},
+		{"AAPL 19JAN24 190 C", "AAPL 01/19/2024 190.00 C", "AAPL", 100, 100},
+		{"AMZN7 01/19/2024 150.00 C", "AMZN7 01/19/2024 150.00 C", "AMZN", 10, 10},
+		{"TSLA1 01/19/2024 250.00 P", "TSLA1 01/19/2024 250.00 P", "TSLA", 100, 100},
+		{"SPXW 01/19/2024 4700.00 P", "SPXW 01/19/2024 4700.00 P", "SPX", 100, 100},
+	}
+	for _, tt := range tests {
+		o, ok := transaction.ParseOption(tt.symbol)
+		if !ok {
+			t.Errorf("%s: not read as an option", tt.symbol)
+			continue
+		}
+		if o.Symbol() != tt.expected || o.Underlying != tt.underlying ||
+			o.SharesPerContract() != tt.shares || o.Multiplier != tt.multiplier {
+			t.Errorf("%s: expected %s of %s, %v shares and x%v, got %s of %s, %v shares and x%v", tt.symbol,
+				tt.expected, tt.underlying, tt.shares, tt.multiplier,
+				o.Symbol(), o.Underlying, o.SharesPerContract(), o.Multiplier)
+		}
+	}
+
+	for _, symbol := range []string{"AAPL", "BRK.B", "AAPL 01/19/2024 190.00", "AAPL 01/19/2024 abc C"} {
+		if _, ok := transaction.ParseOption(symbol); ok {
+			t.Errorf("%s: read as an option", symbol)
+		}
+	}
+}
+
+func TestAdjustedOptionUnderlying(t *testing.T) {
+	if s := transaction.SymbolFromOptionSymbol("TSLA1 01/19/2024 250.00 P"); s != "TSLA" {
+		t.Errorf("Expected the underlying TSLA, got %s", s)
+	}
+	if s := transaction.SymbolFromOptionSymbol("TSLA"); s != "TSLA" {
+		t.Errorf("Expected TSLA, got %s", s)
+	}
+	mini := transaction.Transaction{Symbol: "AMZN7 01/19/2024 150.00 C"}
+	if !transaction.IsOption(mini) || mini.SharesPerContract() != 10 {
+		t.Errorf("Expected a mini option of 10 shares, got %v", mini.SharesPerContract())
+	}
+}
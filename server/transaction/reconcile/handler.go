@@ -0,0 +1,178 @@
+package reconcile
+
+import (
+	"math"
+	"sort"
+
+	"github.com/wazupwiddat/postrack/server/accountalias"
+	"github.com/wazupwiddat/postrack/server/config"
+	"github.com/wazupwiddat/postrack/server/corporateaction"
+	"github.com/wazupwiddat/postrack/server/schwab"
+	"github.com/wazupwiddat/postrack/server/split"
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/user"
+	schwabClient "github.com/wazupwiddat/schwab-api/client"
+	"gorm.io/gorm"
+)
+
+// quantityTolerance absorbs rounding in fractional share quantities.
+const quantityTolerance = 0.0001
+
+type Request struct {
+	User *user.User
+}
+
+// BrokerPosition is an open position as the broker reports it.  Quantity is
+// negative for short positions.
+type BrokerPosition struct {
+	Account  string
+	Symbol   string
+	Quantity float64
+}
+
+// Difference is a symbol whose open quantity postrack computes differently
+// from the broker.
+type Difference struct {
+	Account  string
+	Symbol   string
+	Computed float64
+	Broker   float64
+}
+
+type Response struct {
+	Accounts    []string
+	Differences []Difference
+}
+
+// Reconcile compares the open positions computed from the user's stored
+// transactions with the positions Schwab reports for the linked accounts.
+func Reconcile(db *gorm.DB, cfg *config.Config, req *Request) (*Response, error) {
+	token, err := schwab.FindActiveByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	sClient := schwabClient.NewSchwabClient(cfg.Schwab.ClientID, cfg.Schwab.ClientSecret, cfg.Schwab.AuthRedirect)
+	broker, err := FetchSchwabPositions(sClient, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := accountalias.FindAllByUser(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range broker {
+		broker[idx].Account = aliases.Resolve(broker[idx].Account)
+	}
+
+	trans, err := transaction.FindAllByUser(db, req.User)
+	if err != nil {
+		return nil, err
+	}
+	splits, err := split.FindConfirmed(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := corporateaction.FindApplied(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	t := transaction.Transactions(trans)
+	positions := t.MergeTransactions(splits, actions).CollectPositions()
+
+	accounts, differences := Compare(positions, broker)
+	return &Response{
+		Accounts:    accounts,
+		Differences: differences,
+	}, nil
+}
+
+// FetchSchwabPositions returns the open positions of every account the
+// token can see, keyed by the masked account number.
+func FetchSchwabPositions(sClient *schwabClient.SchwabAPIClient, accessToken string) ([]BrokerPosition, error) {
+	accounts, err := sClient.GetAccounts(accessToken, true)
+	if err != nil {
+		return nil, err
+	}
+	positions := []BrokerPosition{}
+	for _, a := range accounts {
+		account := a.SecuritiesAccount
+		for _, p := range account.Positions {
+			// sweep and money market balances are cash, not positions
+			if p.Instrument.AssetType == "CASH_EQUIVALENT" {
+				continue
+			}
+			symbol := p.Instrument.Symbol
+			if p.Instrument.AssetType == "OPTION" {
+				if s, ok := transaction.OCCToSymbol(symbol); ok {
+					symbol = s
+				}
+			}
+			positions = append(positions, BrokerPosition{
+				Account:  schwab.AccountMask(account.AccountNumber),
+				Symbol:   symbol,
+				Quantity: p.LongQuantity - p.ShortQuantity,
+			})
+		}
+	}
+	return positions, nil
+}
+
+// Compare lists the symbols, in the accounts the broker reports on, whose
+// computed open quantity is not what the broker has.  Every position counts
+// whatever its disposition: one that sold more than it bought is closed, but
+// the missing shares are what reconciling should find.
+func Compare(positions transaction.Positions, broker []BrokerPosition) ([]string, []Difference) {
+	type key struct {
+		account string
+		symbol  string
+	}
+	accounts := map[string]bool{}
+	brokerQuantity := map[key]float64{}
+	for _, b := range broker {
+		accounts[b.Account] = true
+		brokerQuantity[key{b.Account, b.Symbol}] += b.Quantity
+	}
+
+	computedQuantity := map[key]float64{}
+	for _, pos := range positions {
+		if !accounts[pos.Account] {
+			continue
+		}
+		computedQuantity[key{pos.Account, pos.Symbol}] += pos.Quantity
+	}
+
+	keys := map[key]bool{}
+	for k := range brokerQuantity {
+		keys[k] = true
+	}
+	for k := range computedQuantity {
+		keys[k] = true
+	}
+
+	differences := []Difference{}
+	for k := range keys {
+		if math.Abs(computedQuantity[k]-brokerQuantity[k]) < quantityTolerance {
+			continue
+		}
+		differences = append(differences, Difference{
+			Account:  k.account,
+			Symbol:   k.symbol,
+			Computed: computedQuantity[k],
+			Broker:   brokerQuantity[k],
+		})
+	}
+	sort.Slice(differences, func(i, j int) bool {
+		if differences[i].Account != differences[j].Account {
+			return differences[i].Account < differences[j].Account
+		}
+		return differences[i].Symbol < differences[j].Symbol
+	})
+
+	names := []string{}
+	for a := range accounts {
+		names = append(names, a)
+	}
+	sort.Strings(names)
+	return names, differences
+}
@@ -0,0 +1,77 @@
+package reconcile_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wazupwiddat/postrack/server/transaction"
+	"github.com/wazupwiddat/postrack/server/transaction/reconcile"
+)
+
+func TestCompare(t *testing.T) {
+	trans := transaction.Transactions{
+		{Account: "...678", Date: date("01/02/2024"), Action: "Buy", Symbol: "AAPL", Quantity: 100},
+		{Account: "...678", Date: date("01/03/2024"), Action: "Sell to Open", Symbol: "AAPL 01/19/2024 190.00 C", Quantity: 1},
+		{Account: "...678", Date: date("01/04/2024"), Action: "Buy", Symbol: "MSFT", Quantity: 10},
+		{Account: "...678", Date: date("01/05/2024"), Action: "Sell", Symbol: "MSFT", Quantity: 10},
+		{Account: "...678", Date: date("01/05/2024"), Action: "Sell to Open", Symbol: "SPY 01/19/2024 470.00 P", Quantity: 2},
+		// not linked to the broker, so not reconciled
+		{Account: "Fidelity", Date: date("01/02/2024"), Action: "Buy", Symbol: "QQQ", Quantity: 5},
+	}
+	positions := trans.MergeTransactions(nil, nil).CollectPositions()
+
+	broker := []reconcile.BrokerPosition{
+		{Account: "...678", Symbol: "AAPL", Quantity: 100},
+		{Account: "...678", Symbol: "AAPL 01/19/2024 190.00 C", Quantity: -1},
+		{Account: "...678", Symbol: "TSLA", Quantity: 30},
+	}
+
+	accounts, differences := reconcile.Compare(positions, broker)
+	if len(accounts) != 1 || accounts[0] != "...678" {
+		t.Errorf("Expected only ...678 to be reconciled, got %v", accounts)
+	}
+	expected := []reconcile.Difference{
+		{Account: "...678", Symbol: "SPY 01/19/2024 470.00 P", Computed: -2, Broker: 0},
+		{Account: "...678", Symbol: "TSLA", Computed: 0, Broker: 30},
+	}
+	if len(differences) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, differences)
+	}
+	for i, d := range differences {
+		if d != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], d)
+		}
+	}
+}
+
+func date(value string) time.Time {
+	d, _ := time.Parse("01/02/2006", value)
+	return d
+}
+
+func TestCompareOverSold(t *testing.T) {
+	// the buy of the other 5 shares was never imported
+	trans := transaction.Transactions{
+		{Account: "...678", Date: date("01/02/2024"), Action: "Buy", Symbol: "NVDA", Quantity: 10},
+		{Account: "...678", Date: date("01/08/2024"), Action: "Sell", Symbol: "NVDA", Quantity: 15},
+	}
+	positions := trans.MergeTransactions(nil, nil).CollectPositions()
+
+	broker := []reconcile.BrokerPosition{
+		{Account: "...678", Symbol: "AAPL", Quantity: 100},
+	}
+
+	_, differences := reconcile.Compare(positions, broker)
+	expected := []reconcile.Difference{
+		{Account: "...678", Symbol: "AAPL", Computed: 0, Broker: 100},
+		{Account: "...678", Symbol: "NVDA", Computed: -5, Broker: 0},
+	}
+	if len(differences) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, differences)
+	}
+	for i, d := range differences {
+		if d != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], d)
+		}
+	}
+}
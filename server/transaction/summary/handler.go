@@ -5,6 +5,9 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/shopspring/decimal"
+	"github.com/wazupwiddat/postrack/server/corporateaction"
+	"github.com/wazupwiddat/postrack/server/split"
 	"github.com/wazupwiddat/postrack/server/transaction"
 	"github.com/wazupwiddat/postrack/server/user"
 	"gorm.io/gorm"
@@ -16,17 +19,17 @@ type Request struct {
 
 type OpenSummary struct {
 	Account string
-	Value   float64
+	Value   decimal.Decimal
 }
 
 type ClosedSummaryByMonth struct {
 	Month string
-	Value []float64
+	Value []decimal.Decimal
 }
 
 type ClosedSummaryByYear struct {
 	Year  string
-	Value []float64
+	Value []decimal.Decimal
 }
 
 type Response struct {
@@ -41,8 +44,16 @@ func Summary(db *gorm.DB, req *Request) (*Response, error) {
 	if err != nil {
 		return nil, err
 	}
+	splits, err := split.FindConfirmed(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := corporateaction.FindApplied(db, req.User.ID)
+	if err != nil {
+		return nil, err
+	}
 	t := transaction.Transactions(trans)
-	mergedTransactions := t.MergeTransactions()
+	mergedTransactions := t.MergeTransactions(splits, actions)
 	positions := mergedTransactions.CollectPositions()
 
 	// Accounts with positions
@@ -72,16 +83,16 @@ func Summary(db *gorm.DB, req *Request) (*Response, error) {
 		closedShorts := positions.SumProduct(transaction.PositionSummerAmount,
 			transaction.ShortPositionCondition,
 			func(pos transaction.Position) bool {
-				td, _ := time.Parse("01/02/2006", pos.Transactions[0].Date)
+				td := pos.Transactions[0].Date
 				return td.Month() == safeDate.Month() && td.Year() == safeDate.Year()
 			},
 		)
 		cs := ClosedSummaryByMonth{
 			Month: safeDate.Month().String(),
 		}
-		total := 0.0
+		total := decimal.Zero
 		for _, a := range accounts {
-			total += closedShorts[a].Value
+			total = total.Add(closedShorts[a].Value)
 			cs.Value = append(cs.Value, closedShorts[a].Value)
 		}
 		cs.Value = append(cs.Value, total)
@@ -96,16 +107,15 @@ func Summary(db *gorm.DB, req *Request) (*Response, error) {
 		closedShorts := positions.SumProduct(transaction.PositionSummerAmount,
 			transaction.ShortPositionCondition,
 			func(pos transaction.Position) bool {
-				td, _ := time.Parse("01/02/2006", pos.Transactions[0].Date)
-				return td.Year() == safeDate.Year()
+				return pos.Transactions[0].Date.Year() == safeDate.Year()
 			},
 		)
 		cs := ClosedSummaryByYear{
 			Year: strconv.Itoa(safeDate.Year()),
 		}
-		total := 0.0
+		total := decimal.Zero
 		for _, a := range accounts {
-			total += closedShorts[a].Value
+			total = total.Add(closedShorts[a].Value)
 			cs.Value = append(cs.Value, closedShorts[a].Value)
 		}
 		cs.Value = append(cs.Value, total)
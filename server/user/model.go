@@ -11,6 +11,7 @@ type User struct {
 	ID           uint   `gorm:"primary_key"`
 	Email        string `gorm:"size:100;unique;not null"`
 	PasswordHash string `gorm:"size:100"`
+	Admin        bool   // can change what is shared by every user, such as splits
 }
 
 const (